@@ -0,0 +1,75 @@
+package acsp
+
+// RealtimePosInterval asks the server to send a CarUpdate for every car each
+// intervalMS milliseconds. An interval of 0 disables car updates.
+func RealtimePosInterval(intervalMS uint16) []byte {
+	return NewWriter(CmdRealtimePosInterval).Uint16(intervalMS).Bytes()
+}
+
+// GetCarInfo asks the server for a CarInfo describing carID.
+func GetCarInfo(carID uint8) []byte {
+	return NewWriter(CmdGetCarInfo).Uint8(carID).Bytes()
+}
+
+// CurrentSession can be passed to GetSessionInfo to ask for the session
+// currently running.
+const CurrentSession int16 = -1
+
+// GetSessionInfo asks the server for a SessionInfo describing the session at
+// sessionIndex.
+func GetSessionInfo(sessionIndex int16) []byte {
+	return NewWriter(CmdGetSessionInfo).Int16(sessionIndex).Bytes()
+}
+
+// SendChat sends a private chat message to the driver in carID.
+func SendChat(carID uint8, message string) []byte {
+	return NewWriter(CmdSendChat).Uint8(carID).WideString(message).Bytes()
+}
+
+// BroadcastChat sends a chat message to every driver.
+func BroadcastChat(message string) []byte {
+	return NewWriter(CmdBroadcastChat).WideString(message).Bytes()
+}
+
+// KickUser kicks the driver in carID from the server.
+func KickUser(carID uint8) []byte {
+	return NewWriter(CmdKickUser).Uint8(carID).Bytes()
+}
+
+// NextSession skips to the next session.
+func NextSession() []byte {
+	return NewWriter(CmdNextSession).Bytes()
+}
+
+// RestartSession restarts the current session.
+func RestartSession() []byte {
+	return NewWriter(CmdRestartSession).Bytes()
+}
+
+// AdminCommand runs a server admin command such as "/ballast 0 50", as if
+// typed in chat by an admin.
+func AdminCommand(command string) []byte {
+	return NewWriter(CmdAdminCommand).WideString(command).Bytes()
+}
+
+// SessionSettings changes the settings of a session with SetSessionInfo.
+type SessionSettings struct {
+	SessionIndex uint8
+	Name         string
+	Type         SessionType
+	Laps         uint32
+	Time         uint32 // seconds
+	WaitTime     uint32 // seconds
+}
+
+// SetSessionInfo changes the settings of a session.
+func SetSessionInfo(s SessionSettings) []byte {
+	return NewWriter(CmdSetSessionInfo).
+		Uint8(s.SessionIndex).
+		WideString(s.Name).
+		Uint8(uint8(s.Type)).
+		Uint32(s.Laps).
+		Uint32(s.Time).
+		Uint32(s.WaitTime).
+		Bytes()
+}
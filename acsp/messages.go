@@ -0,0 +1,278 @@
+package acsp
+
+import "fmt"
+
+// Message is a decoded server-to-plugin message.
+type Message interface {
+	// MsgType returns the ACSP type byte of the message.
+	MsgType() uint8
+}
+
+// SessionInfo describes a session, sent in reply to CmdGetSessionInfo.
+type SessionInfo struct {
+	Version             uint8
+	SessionIndex        uint8
+	CurrentSessionIndex uint8
+	SessionCount        uint8
+	ServerName          string
+	Track               string
+	TrackConfig         string
+	Name                string
+	Type                SessionType
+	Time                uint16 // minutes
+	Laps                uint16
+	WaitTime            uint16 // seconds
+	AmbientTemp         uint8
+	RoadTemp            uint8
+	WeatherGraphics     string
+	ElapsedMS           int32
+}
+
+// NewSession is sent when a new session starts. It has the same layout as
+// SessionInfo.
+type NewSession struct {
+	SessionInfo
+}
+
+// NewConnection is sent when a driver joins the server.
+type NewConnection struct {
+	DriverName string
+	DriverGUID string
+	CarID      uint8
+	CarModel   string
+	CarSkin    string
+}
+
+// ConnectionClosed is sent when a driver leaves the server. It has the same
+// layout as NewConnection.
+type ConnectionClosed struct {
+	NewConnection
+}
+
+// CarUpdate is the realtime position report sent for every connected car at
+// the interval requested with CmdRealtimePosInterval.
+type CarUpdate struct {
+	CarID               uint8
+	Pos                 Vec3
+	Velocity            Vec3
+	Gear                uint8
+	EngineRPM           uint16
+	NormalizedSplinePos float32
+}
+
+// CarInfo describes a car slot, sent in reply to CmdGetCarInfo.
+type CarInfo struct {
+	CarID       uint8
+	IsConnected bool
+	CarModel    string
+	CarSkin     string
+	DriverName  string
+	DriverTeam  string
+	DriverGUID  string
+}
+
+// EndSession is sent when a session ends and carries the path of the results
+// JSON the server has written.
+type EndSession struct {
+	ReportFile string
+}
+
+// Version carries the protocol version of the server.
+type Version struct {
+	ProtocolVersion uint8
+}
+
+// Chat is a chat message sent by a driver.
+type Chat struct {
+	CarID   uint8
+	Message string
+}
+
+// ClientLoaded is sent when a driver has finished loading and is on track.
+type ClientLoaded struct {
+	CarID uint8
+}
+
+// Error is an error reported by the server, usually in reply to a malformed
+// command.
+type Error struct {
+	Message string
+}
+
+// LeaderboardEntry is one row of the leaderboard carried by LapCompleted, in
+// leaderboard order.
+type LeaderboardEntry struct {
+	CarID     uint8
+	Time      uint32 // best lap in ms
+	Laps      uint16
+	Completed bool
+}
+
+// LapCompleted is sent when a car crosses the finish line.
+type LapCompleted struct {
+	CarID       uint8
+	LapTime     uint32 // ms
+	Cuts        uint8
+	Leaderboard []LeaderboardEntry
+	GripLevel   float32
+}
+
+// ClientEvent reports a collision. OtherCarID is only meaningful for
+// CollisionWithCar.
+type ClientEvent struct {
+	Type        ClientEventType
+	CarID       uint8
+	OtherCarID  uint8
+	ImpactSpeed float32 // km/h
+	WorldPos    Vec3
+	RelPos      Vec3
+}
+
+func (*SessionInfo) MsgType() uint8      { return MsgSessionInfo }
+func (*NewSession) MsgType() uint8       { return MsgNewSession }
+func (*NewConnection) MsgType() uint8    { return MsgNewConnection }
+func (*ConnectionClosed) MsgType() uint8 { return MsgConnectionClosed }
+func (*CarUpdate) MsgType() uint8        { return MsgCarUpdate }
+func (*CarInfo) MsgType() uint8          { return MsgCarInfo }
+func (*EndSession) MsgType() uint8       { return MsgEndSession }
+func (*Version) MsgType() uint8          { return MsgVersion }
+func (*Chat) MsgType() uint8             { return MsgChat }
+func (*ClientLoaded) MsgType() uint8     { return MsgClientLoaded }
+func (*Error) MsgType() uint8            { return MsgError }
+func (*LapCompleted) MsgType() uint8     { return MsgLapCompleted }
+func (*ClientEvent) MsgType() uint8      { return MsgClientEvent }
+
+// UnknownTypeError is returned by Decode for a type byte it does not know.
+type UnknownTypeError uint8
+
+func (e UnknownTypeError) Error() string {
+	return fmt.Sprintf("acsp: unknown message type %d", uint8(e))
+}
+
+// Decode decodes a single datagram received from the server.
+func Decode(data []byte) (Message, error) {
+	if len(data) == 0 {
+		return nil, ErrTruncated
+	}
+	r := NewReader(data[1:])
+
+	var msg Message
+	switch data[0] {
+	case MsgSessionInfo:
+		msg = readSessionInfo(r)
+	case MsgNewSession:
+		msg = &NewSession{SessionInfo: *readSessionInfo(r)}
+	case MsgNewConnection:
+		msg = readNewConnection(r)
+	case MsgConnectionClosed:
+		msg = &ConnectionClosed{NewConnection: *readNewConnection(r)}
+	case MsgCarUpdate:
+		msg = &CarUpdate{
+			CarID:               r.Uint8(),
+			Pos:                 r.Vec3(),
+			Velocity:            r.Vec3(),
+			Gear:                r.Uint8(),
+			EngineRPM:           r.Uint16(),
+			NormalizedSplinePos: r.Float32(),
+		}
+	case MsgCarInfo:
+		msg = &CarInfo{
+			CarID:       r.Uint8(),
+			IsConnected: r.Bool(),
+			CarModel:    r.WideString(),
+			CarSkin:     r.WideString(),
+			DriverName:  r.WideString(),
+			DriverTeam:  r.WideString(),
+			DriverGUID:  r.WideString(),
+		}
+	case MsgEndSession:
+		msg = &EndSession{ReportFile: r.WideString()}
+	case MsgVersion:
+		msg = &Version{ProtocolVersion: r.Uint8()}
+	case MsgChat:
+		msg = &Chat{CarID: r.Uint8(), Message: r.WideString()}
+	case MsgClientLoaded:
+		msg = &ClientLoaded{CarID: r.Uint8()}
+	case MsgError:
+		msg = &Error{Message: r.WideString()}
+	case MsgLapCompleted:
+		msg = readLapCompleted(r)
+	case MsgClientEvent:
+		msg = readClientEvent(r)
+	default:
+		return nil, UnknownTypeError(data[0])
+	}
+
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("decoding message type %d: %w", data[0], err)
+	}
+	return msg, nil
+}
+
+// Struct literal fields are evaluated in order, which matches the order of
+// the fields on the wire.
+
+func readSessionInfo(r *Reader) *SessionInfo {
+	return &SessionInfo{
+		Version:             r.Uint8(),
+		SessionIndex:        r.Uint8(),
+		CurrentSessionIndex: r.Uint8(),
+		SessionCount:        r.Uint8(),
+		ServerName:          r.WideString(),
+		Track:               r.NarrowString(),
+		TrackConfig:         r.NarrowString(),
+		Name:                r.NarrowString(),
+		Type:                SessionType(r.Uint8()),
+		Time:                r.Uint16(),
+		Laps:                r.Uint16(),
+		WaitTime:            r.Uint16(),
+		AmbientTemp:         r.Uint8(),
+		RoadTemp:            r.Uint8(),
+		WeatherGraphics:     r.NarrowString(),
+		ElapsedMS:           r.Int32(),
+	}
+}
+
+func readNewConnection(r *Reader) *NewConnection {
+	return &NewConnection{
+		DriverName: r.WideString(),
+		DriverGUID: r.WideString(),
+		CarID:      r.Uint8(),
+		CarModel:   r.NarrowString(),
+		CarSkin:    r.NarrowString(),
+	}
+}
+
+func readLapCompleted(r *Reader) *LapCompleted {
+	lap := &LapCompleted{
+		CarID:   r.Uint8(),
+		LapTime: r.Uint32(),
+		Cuts:    r.Uint8(),
+	}
+	count := int(r.Uint8())
+	lap.Leaderboard = make([]LeaderboardEntry, 0, count)
+	for i := 0; i < count && r.Err() == nil; i++ {
+		lap.Leaderboard = append(lap.Leaderboard, LeaderboardEntry{
+			CarID:     r.Uint8(),
+			Time:      r.Uint32(),
+			Laps:      r.Uint16(),
+			Completed: r.Bool(),
+		})
+	}
+	lap.GripLevel = r.Float32()
+	return lap
+}
+
+func readClientEvent(r *Reader) *ClientEvent {
+	ev := &ClientEvent{
+		Type:  ClientEventType(r.Uint8()),
+		CarID: r.Uint8(),
+	}
+	if ev.Type == CollisionWithCar {
+		ev.OtherCarID = r.Uint8()
+	}
+	ev.ImpactSpeed = r.Float32()
+	ev.WorldPos = r.Vec3()
+	ev.RelPos = r.Vec3()
+	return ev
+}
@@ -0,0 +1,285 @@
+package acsp
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// narrow appends a narrow string, which Writer does not need for commands.
+func narrow(w *Writer, s string) *Writer {
+	w.Uint8(uint8(len(s)))
+	w.buf = append(w.buf, s...)
+	return w
+}
+
+func vec3(w *Writer, v Vec3) *Writer {
+	return w.Float32(v.X).Float32(v.Y).Float32(v.Z)
+}
+
+func sessionInfoPayload(msgType uint8) []byte {
+	w := NewWriter(msgType).Uint8(4).Uint8(1).Uint8(1).Uint8(3).WideString("Zoë 日本")
+	narrow(w, "ks_nordschleife")
+	narrow(w, "touristenfahrten")
+	narrow(w, "Race")
+	w.Uint8(uint8(SessionRace)).Uint16(0).Uint16(10).Uint16(60).Uint8(22).Uint8(31)
+	narrow(w, "3_clear")
+	return w.Uint32(uint32(12345)).Bytes()
+}
+
+var sessionInfo = SessionInfo{
+	Version:             4,
+	SessionIndex:        1,
+	CurrentSessionIndex: 1,
+	SessionCount:        3,
+	ServerName:          "Zoë 日本",
+	Track:               "ks_nordschleife",
+	TrackConfig:         "touristenfahrten",
+	Name:                "Race",
+	Type:                SessionRace,
+	Laps:                10,
+	WaitTime:            60,
+	AmbientTemp:         22,
+	RoadTemp:            31,
+	WeatherGraphics:     "3_clear",
+	ElapsedMS:           12345,
+}
+
+func connectionPayload(msgType uint8) []byte {
+	w := NewWriter(msgType).WideString("Zoë 日本").WideString("76561198000000000").Uint8(7)
+	narrow(w, "ks_mazda_mx5_cup")
+	narrow(w, "00_red")
+	return w.Bytes()
+}
+
+var connection = NewConnection{
+	DriverName: "Zoë 日本",
+	DriverGUID: "76561198000000000",
+	CarID:      7,
+	CarModel:   "ks_mazda_mx5_cup",
+	CarSkin:    "00_red",
+}
+
+func TestDecode(t *testing.T) {
+	pos := Vec3{X: 1.5, Y: -2, Z: 300.25}
+	vel := Vec3{X: 10, Y: 0, Z: -4}
+
+	tests := []struct {
+		name string
+		data []byte
+		want Message
+	}{
+		{
+			name: "session info",
+			data: sessionInfoPayload(MsgSessionInfo),
+			want: &sessionInfo,
+		},
+		{
+			name: "new session",
+			data: sessionInfoPayload(MsgNewSession),
+			want: &NewSession{SessionInfo: sessionInfo},
+		},
+		{
+			name: "new connection",
+			data: connectionPayload(MsgNewConnection),
+			want: &connection,
+		},
+		{
+			name: "connection closed",
+			data: connectionPayload(MsgConnectionClosed),
+			want: &ConnectionClosed{NewConnection: connection},
+		},
+		{
+			name: "car update",
+			data: vec3(vec3(NewWriter(MsgCarUpdate).Uint8(3), pos), vel).
+				Uint8(4).Uint16(7200).Float32(0.5).Bytes(),
+			want: &CarUpdate{CarID: 3, Pos: pos, Velocity: vel, Gear: 4, EngineRPM: 7200, NormalizedSplinePos: 0.5},
+		},
+		{
+			name: "car info",
+			data: NewWriter(MsgCarInfo).Uint8(2).Uint8(1).
+				WideString("ks_mazda_mx5_cup").WideString("00_red").
+				WideString("Zoë 日本").WideString("Équipe").WideString("76561198000000000").Bytes(),
+			want: &CarInfo{
+				CarID:       2,
+				IsConnected: true,
+				CarModel:    "ks_mazda_mx5_cup",
+				CarSkin:     "00_red",
+				DriverName:  "Zoë 日本",
+				DriverTeam:  "Équipe",
+				DriverGUID:  "76561198000000000",
+			},
+		},
+		{
+			name: "end session",
+			data: NewWriter(MsgEndSession).WideString(`C:\acserver\results\2026_10_16_RACE.json`).Bytes(),
+			want: &EndSession{ReportFile: `C:\acserver\results\2026_10_16_RACE.json`},
+		},
+		{
+			name: "version",
+			data: NewWriter(MsgVersion).Uint8(4).Bytes(),
+			want: &Version{ProtocolVersion: 4},
+		},
+		{
+			name: "chat",
+			data: NewWriter(MsgChat).Uint8(5).WideString("Zoë 日本 👋").Bytes(),
+			want: &Chat{CarID: 5, Message: "Zoë 日本 👋"},
+		},
+		{
+			name: "client loaded",
+			data: NewWriter(MsgClientLoaded).Uint8(9).Bytes(),
+			want: &ClientLoaded{CarID: 9},
+		},
+		{
+			name: "error",
+			data: NewWriter(MsgError).WideString("bad session index").Bytes(),
+			want: &Error{Message: "bad session index"},
+		},
+		{
+			name: "lap completed",
+			data: NewWriter(MsgLapCompleted).Uint8(1).Uint32(92345).Uint8(2).
+				Uint8(2).
+				Uint8(1).Uint32(92345).Uint16(5).Uint8(0).
+				Uint8(0).Uint32(93000).Uint16(4).Uint8(1).
+				Float32(0.98).Bytes(),
+			want: &LapCompleted{
+				CarID:   1,
+				LapTime: 92345,
+				Cuts:    2,
+				Leaderboard: []LeaderboardEntry{
+					{CarID: 1, Time: 92345, Laps: 5},
+					{CarID: 0, Time: 93000, Laps: 4, Completed: true},
+				},
+				GripLevel: 0.98,
+			},
+		},
+		{
+			name: "lap completed with empty leaderboard",
+			data: NewWriter(MsgLapCompleted).Uint8(1).Uint32(92345).Uint8(0).Uint8(0).Float32(1).Bytes(),
+			want: &LapCompleted{CarID: 1, LapTime: 92345, Leaderboard: []LeaderboardEntry{}, GripLevel: 1},
+		},
+		{
+			name: "collision with car",
+			data: vec3(vec3(NewWriter(MsgClientEvent).Uint8(uint8(CollisionWithCar)).Uint8(1).Uint8(2).Float32(42.5), pos), vel).Bytes(),
+			want: &ClientEvent{Type: CollisionWithCar, CarID: 1, OtherCarID: 2, ImpactSpeed: 42.5, WorldPos: pos, RelPos: vel},
+		},
+		{
+			name: "collision with environment",
+			data: vec3(vec3(NewWriter(MsgClientEvent).Uint8(uint8(CollisionWithEnv)).Uint8(1).Float32(12), pos), vel).Bytes(),
+			want: &ClientEvent{Type: CollisionWithEnv, CarID: 1, ImpactSpeed: 12, WorldPos: pos, RelPos: vel},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(tt.data)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode = %+v, want %+v", got, tt.want)
+			}
+			if got.MsgType() != tt.data[0] {
+				t.Errorf("MsgType = %d, want %d", got.MsgType(), tt.data[0])
+			}
+		})
+	}
+}
+
+func trimEnd(b []byte, n int) []byte {
+	return b[:len(b)-n]
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "empty", data: nil, want: ErrTruncated},
+		{name: "missing field", data: []byte{MsgClientLoaded}, want: ErrTruncated},
+		{name: "short wide string", data: NewWriter(MsgChat).Uint8(1).Uint8(3).Uint32('a').Bytes(), want: ErrTruncated},
+		{name: "short narrow string", data: trimEnd(connectionPayload(MsgNewConnection), 3), want: ErrTruncated},
+		{name: "short leaderboard", data: NewWriter(MsgLapCompleted).Uint8(1).Uint32(1).Uint8(0).Uint8(3).Uint8(1).Bytes(), want: ErrTruncated},
+		{name: "unknown type", data: []byte{99, 1, 2}, want: UnknownTypeError(99)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Decode(tt.data)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Decode error = %v, want %v", err, tt.want)
+			}
+			if msg != nil {
+				t.Errorf("Decode returned %+v alongside error", msg)
+			}
+		})
+	}
+}
+
+func TestNarrowStringInvalidUTF8(t *testing.T) {
+	r := NewReader([]byte{4, 'a', 0xff, 'b', 0})
+	if got, want := r.NarrowString(), "a\uFFFDb"; got != want {
+		t.Errorf("NarrowString = %q, want %q", got, want)
+	}
+}
+
+func TestWideStringInvalidCodePoint(t *testing.T) {
+	r := NewReader(NewWriter(0).Uint8(2).Uint32('a').Uint32(0xD800).Bytes()[1:])
+	if got, want := r.WideString(), "a\uFFFD"; got != want {
+		t.Errorf("WideString = %q, want %q", got, want)
+	}
+}
+
+func TestSetSessionInfoRoundTrip(t *testing.T) {
+	want := SessionSettings{
+		SessionIndex: 2,
+		Name:         "Zoë 日本",
+		Type:         SessionRace,
+		Laps:         12,
+		Time:         1800,
+		WaitTime:     90,
+	}
+	data := SetSessionInfo(want)
+	if data[0] != CmdSetSessionInfo {
+		t.Fatalf("type = %d, want %d", data[0], CmdSetSessionInfo)
+	}
+
+	r := NewReader(data[1:])
+	got := SessionSettings{
+		SessionIndex: r.Uint8(),
+		Name:         r.WideString(),
+		Type:         SessionType(r.Uint8()),
+		Laps:         r.Uint32(),
+		Time:         r.Uint32(),
+		WaitTime:     r.Uint32(),
+	}
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 0 {
+		t.Errorf("%d trailing bytes", r.Len())
+	}
+	if got != want {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestSendChatRoundTrip(t *testing.T) {
+	data := SendChat(4, "Zoë 日本 👋")
+	if data[0] != CmdSendChat {
+		t.Fatalf("type = %d, want %d", data[0], CmdSendChat)
+	}
+
+	r := NewReader(data[1:])
+	carID, message := r.Uint8(), r.WideString()
+	if err := r.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 0 {
+		t.Errorf("%d trailing bytes", r.Len())
+	}
+	if carID != 4 || message != "Zoë 日本 👋" {
+		t.Errorf("round trip = (%d, %q), want (4, %q)", carID, message, "Zoë 日本 👋")
+	}
+}
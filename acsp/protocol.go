@@ -0,0 +1,83 @@
+// Package acsp decodes and encodes the Assetto Corsa server UDP plugin
+// protocol (ACSP). Every message starts with a single type byte followed by
+// a little-endian payload; strings are either "narrow" (1-byte length +
+// ASCII bytes) or "wide" (1-byte length + UTF-32LE code units).
+package acsp
+
+// Message types sent by the server to the plugin.
+const (
+	MsgNewSession       uint8 = 50
+	MsgNewConnection    uint8 = 51
+	MsgConnectionClosed uint8 = 52
+	MsgCarUpdate        uint8 = 53
+	MsgCarInfo          uint8 = 54
+	MsgEndSession       uint8 = 55
+	MsgVersion          uint8 = 56
+	MsgChat             uint8 = 57
+	MsgClientLoaded     uint8 = 58
+	MsgSessionInfo      uint8 = 59
+	MsgError            uint8 = 60
+	MsgLapCompleted     uint8 = 73
+	MsgClientEvent      uint8 = 130
+)
+
+// Commands sent by the plugin to the server.
+const (
+	CmdRealtimePosInterval uint8 = 200
+	CmdGetCarInfo          uint8 = 201
+	CmdSendChat            uint8 = 202
+	CmdBroadcastChat       uint8 = 203
+	CmdGetSessionInfo      uint8 = 204
+	CmdSetSessionInfo      uint8 = 205
+	CmdKickUser            uint8 = 206
+	CmdNextSession         uint8 = 207
+	CmdRestartSession      uint8 = 208
+	CmdAdminCommand        uint8 = 209
+)
+
+// ClientEventType identifies the kind of a client event.
+type ClientEventType uint8
+
+const (
+	CollisionWithCar ClientEventType = 10
+	CollisionWithEnv ClientEventType = 11
+)
+
+func (t ClientEventType) String() string {
+	switch t {
+	case CollisionWithCar:
+		return "car"
+	case CollisionWithEnv:
+		return "env"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the type as "car" or "env".
+func (t ClientEventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// SessionType is the type of a session as reported by the server.
+type SessionType uint8
+
+const (
+	SessionBooking    SessionType = 0
+	SessionPractice   SessionType = 1
+	SessionQualifying SessionType = 2
+	SessionRace       SessionType = 3
+)
+
+func (t SessionType) String() string {
+	switch t {
+	case SessionBooking:
+		return "Booking"
+	case SessionPractice:
+		return "Practice"
+	case SessionQualifying:
+		return "Qualifying"
+	case SessionRace:
+		return "Race"
+	}
+	return "Unknown"
+}
@@ -0,0 +1,131 @@
+package acsp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrTruncated is returned when a message ends before all of its fields
+// could be read.
+var ErrTruncated = errors.New("acsp: message truncated")
+
+// Vec3 is a position or velocity vector in world space.
+type Vec3 struct {
+	X, Y, Z float32
+}
+
+// Reader reads little-endian ACSP fields from a message payload. The first
+// error encountered is sticky: subsequent reads return zero values and Err
+// reports it.
+type Reader struct {
+	buf []byte
+	off int
+	err error
+}
+
+// NewReader returns a Reader over buf.
+func NewReader(buf []byte) *Reader {
+	return &Reader{buf: buf}
+}
+
+// Err returns the first error encountered while reading, if any.
+func (r *Reader) Err() error {
+	return r.err
+}
+
+// Len returns the number of unread bytes.
+func (r *Reader) Len() int {
+	return len(r.buf) - r.off
+}
+
+func (r *Reader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if r.Len() < n {
+		r.err = fmt.Errorf("%w: need %d bytes at offset %d, have %d", ErrTruncated, n, r.off, r.Len())
+		return nil
+	}
+	b := r.buf[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *Reader) Uint8() uint8 {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *Reader) Bool() bool {
+	return r.Uint8() != 0
+}
+
+func (r *Reader) Uint16() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (r *Reader) Uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *Reader) Int32() int32 {
+	return int32(r.Uint32())
+}
+
+func (r *Reader) Float32() float32 {
+	return math.Float32frombits(r.Uint32())
+}
+
+func (r *Reader) Vec3() Vec3 {
+	return Vec3{X: r.Float32(), Y: r.Float32(), Z: r.Float32()}
+}
+
+// NarrowString reads a narrow string: a 1-byte length followed by that many bytes.
+// Invalid UTF-8 sequences are replaced with utf8.RuneError.
+func (r *Reader) NarrowString() string {
+	n := r.Uint8()
+	b := r.next(int(n))
+	if b == nil {
+		return ""
+	}
+	return strings.ToValidUTF8(strings.TrimRight(string(b), "\x00"), string(utf8.RuneError))
+}
+
+// WideString reads a wide string: a 1-byte length in characters followed by
+// that many UTF-32LE code units. Invalid code points are replaced with
+// utf8.RuneError.
+func (r *Reader) WideString() string {
+	n := r.Uint8()
+	b := r.next(int(n) * 4)
+	if b == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.Grow(int(n))
+	for i := 0; i < len(b); i += 4 {
+		c := rune(binary.LittleEndian.Uint32(b[i:]))
+		if c == 0 {
+			break
+		}
+		if !utf8.ValidRune(c) {
+			c = utf8.RuneError
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,62 @@
+package acsp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Writer builds a little-endian ACSP command.
+type Writer struct {
+	buf []byte
+}
+
+// NewWriter returns a Writer for a command of type cmd.
+func NewWriter(cmd uint8) *Writer {
+	return &Writer{buf: []byte{cmd}}
+}
+
+// Bytes returns the encoded command.
+func (w *Writer) Bytes() []byte {
+	return w.buf
+}
+
+func (w *Writer) Uint8(v uint8) *Writer {
+	w.buf = append(w.buf, v)
+	return w
+}
+
+func (w *Writer) Uint16(v uint16) *Writer {
+	w.buf = binary.LittleEndian.AppendUint16(w.buf, v)
+	return w
+}
+
+func (w *Writer) Int16(v int16) *Writer {
+	return w.Uint16(uint16(v))
+}
+
+func (w *Writer) Uint32(v uint32) *Writer {
+	w.buf = binary.LittleEndian.AppendUint32(w.buf, v)
+	return w
+}
+
+func (w *Writer) Float32(v float32) *Writer {
+	return w.Uint32(math.Float32bits(v))
+}
+
+// maxStringLen is the longest string the 1-byte length prefix can describe.
+const maxStringLen = 255
+
+// WideString appends a wide string: a 1-byte length in characters followed
+// by that many UTF-32LE code units. Strings longer than 255 characters are
+// truncated.
+func (w *Writer) WideString(s string) *Writer {
+	runes := []rune(s)
+	if len(runes) > maxStringLen {
+		runes = runes[:maxStringLen]
+	}
+	w.Uint8(uint8(len(runes)))
+	for _, c := range runes {
+		w.Uint32(uint32(c))
+	}
+	return w
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"acserver-exporter/acsp"
+)
+
+// RegisterAdminHandlers registers the admin endpoints on mux, all protected
+// by token. The target server is selected with the "server" parameter, which
+// may be omitted when only one server is monitored.
+func RegisterAdminHandlers(mux *http.ServeMux, token string, monitors *MonitorSet) {
+	handle := func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, RequireAdminToken(token, handler))
+	}
+
+	handle("/admin/realtime-interval", RealtimeIntervalHandler(monitors))
+	handle("/admin/chat", CommandHandler(monitors, func(r *http.Request, m *ACServerMonitor) (func() error, error) {
+		message := r.FormValue("message")
+		if message == "" {
+			return nil, fmt.Errorf("message is required")
+		}
+		if r.FormValue("car_id") == "" {
+			return func() error { return m.BroadcastChat(message) }, nil
+		}
+		carID, err := formUint8(r, "car_id")
+		if err != nil {
+			return nil, err
+		}
+		return func() error { return m.SendChat(carID, message) }, nil
+	}))
+	handle("/admin/kick", CommandHandler(monitors, func(r *http.Request, m *ACServerMonitor) (func() error, error) {
+		carID, err := formUint8(r, "car_id")
+		if err != nil {
+			return nil, err
+		}
+		return func() error { return m.KickUser(carID) }, nil
+	}))
+	handle("/admin/next-session", CommandHandler(monitors, func(r *http.Request, m *ACServerMonitor) (func() error, error) {
+		return m.NextSession, nil
+	}))
+	handle("/admin/restart-session", CommandHandler(monitors, func(r *http.Request, m *ACServerMonitor) (func() error, error) {
+		return m.RestartSession, nil
+	}))
+	handle("/admin/session", CommandHandler(monitors, func(r *http.Request, m *ACServerMonitor) (func() error, error) {
+		settings, err := parseSessionSettings(r)
+		if err != nil {
+			return nil, err
+		}
+		return func() error { return m.SetSessionInfo(settings) }, nil
+	}))
+	handle("/admin/command", CommandHandler(monitors, func(r *http.Request, m *ACServerMonitor) (func() error, error) {
+		command := r.FormValue("command")
+		if command == "" {
+			return nil, fmt.Errorf("command is required")
+		}
+		return func() error { return m.AdminCommand(command) }, nil
+	}))
+}
+
+// RequireAdminToken rejects requests that do not carry the admin token as a
+// bearer token in the Authorization header.
+func RequireAdminToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		given, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="acserver-exporter"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// RealtimeIntervalHandler reports the car update interval on GET and changes
+// it on POST with the new value in milliseconds in the "ms" parameter.
+func RealtimeIntervalHandler(monitors *MonitorSet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m, err := lookupMonitor(monitors, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			ms, err := strconv.ParseUint(r.FormValue("ms"), 10, 16)
+			if err != nil {
+				http.Error(w, "ms must be an integer between 0 and 65535", http.StatusBadRequest)
+				return
+			}
+			if err := m.SetRealtimeInterval(uint16(ms)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "%d\n", m.RealtimeInterval())
+	}
+}
+
+// CommandHandler serves a POST-only endpoint that sends a command to a
+// server. parse validates the request and returns the command to run; its
+// errors are reported as 400 Bad Request.
+func CommandHandler(monitors *MonitorSet, parse func(r *http.Request, m *ACServerMonitor) (func() error, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		m, err := lookupMonitor(monitors, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		run, err := parse(r, m)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := run(); err != nil {
+			http.Error(w, fmt.Sprintf("failed to send command: %v", err), http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("OK\n"))
+	}
+}
+
+// lookupMonitor returns the monitor named by the "server" parameter.
+func lookupMonitor(set *MonitorSet, r *http.Request) (*ACServerMonitor, error) {
+	monitors := set.Monitors()
+	name := r.FormValue("server")
+	if name == "" {
+		if len(monitors) == 1 {
+			return monitors[0], nil
+		}
+		return nil, fmt.Errorf("server is required when more than one server is monitored")
+	}
+	for _, m := range monitors {
+		if m.Name() == name {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown server %q", name)
+}
+
+// parseSessionSettings parses the settings for SET_SESSION_INFO. The command
+// replaces every setting of the session, so all of them are required: a
+// missing one would otherwise silently reset it to 0 or an empty name.
+func parseSessionSettings(r *http.Request) (acsp.SessionSettings, error) {
+	var settings acsp.SessionSettings
+	var err error
+
+	if settings.SessionIndex, err = formUint8(r, "index"); err != nil {
+		return settings, err
+	}
+	if settings.Name = r.FormValue("name"); settings.Name == "" {
+		return settings, fmt.Errorf("name is required")
+	}
+
+	sessionTypes := map[string]acsp.SessionType{
+		"booking":    acsp.SessionBooking,
+		"practice":   acsp.SessionPractice,
+		"qualifying": acsp.SessionQualifying,
+		"race":       acsp.SessionRace,
+	}
+	sessionType, ok := sessionTypes[strings.ToLower(r.FormValue("type"))]
+	if !ok {
+		return settings, fmt.Errorf("type must be one of booking, practice, qualifying, race")
+	}
+	settings.Type = sessionType
+
+	if settings.Laps, err = formUint32(r, "laps"); err != nil {
+		return settings, err
+	}
+	if settings.Time, err = formUint32(r, "time"); err != nil {
+		return settings, err
+	}
+	if settings.WaitTime, err = formUint32(r, "wait_time"); err != nil {
+		return settings, err
+	}
+	return settings, nil
+}
+
+func formUint8(r *http.Request, name string) (uint8, error) {
+	v, err := strconv.ParseUint(r.FormValue(name), 10, 8)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer between 0 and 255", name)
+	}
+	return uint8(v), nil
+}
+
+func formUint32(r *http.Request, name string) (uint32, error) {
+	v, err := strconv.ParseUint(r.FormValue(name), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return uint32(v), nil
+}
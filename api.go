@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RegisterAPIHandlers registers the read-only JSON API on mux. The server is
+// selected with the "server" parameter, which may be omitted when only one
+// server is monitored.
+func RegisterAPIHandlers(mux *http.ServeMux, monitors *MonitorSet) {
+	mux.HandleFunc("/api/server", APIHandler(monitors, serverState))
+	mux.HandleFunc("/api/cars", APIHandler(monitors, func(r *http.Request, m *ACServerMonitor) any {
+		return m.Cars()
+	}))
+	mux.HandleFunc("/api/standings", APIHandler(monitors, standings))
+	mux.HandleFunc("/api/laps", APIHandler(monitors, laps))
+}
+
+// APIHandler serves GET requests with the JSON encoding of what render
+// returns for the selected server.
+func APIHandler(monitors *MonitorSet, render func(r *http.Request, m *ACServerMonitor) any) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		m, err := lookupMonitor(monitors, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if err := json.NewEncoder(w).Encode(render(r, m)); err != nil {
+			slog.Warn("API response failed", "path", r.URL.Path, "err", err)
+		}
+	}
+}
+
+// apiServer is the response of /api/server.
+type apiServer struct {
+	Name          string       `json:"name"` // configured name
+	Up            bool         `json:"up"`
+	Info          *ServerInfo  `json:"info"` // null until /INFO answered
+	InfoFetchedAt *time.Time   `json:"info_fetched_at,omitempty"`
+	Session       SessionState `json:"session"`
+	Players       int          `json:"players"`
+}
+
+func serverState(r *http.Request, m *ACServerMonitor) any {
+	liveness := m.Liveness()
+	server := apiServer{
+		Name:    m.Name(),
+		Up:      m.Up(liveness, time.Now()),
+		Session: m.Session(),
+		Players: m.GetConnectedCount(),
+	}
+	if snapshot := liveness.Info; snapshot != nil {
+		server.Info = snapshot.Info
+		server.InfoFetchedAt = &snapshot.FetchedAt
+	}
+	return server
+}
+
+// apiStanding is a row of /api/standings.
+type apiStanding struct {
+	Position int `json:"position"`
+	Driver
+	BestLap   *uint32 `json:"best_lap_ms"` // null without a valid lap
+	Laps      uint16  `json:"laps"`
+	Completed bool    `json:"completed"`
+}
+
+func standings(r *http.Request, m *ACServerMonitor) any {
+	rows := []apiStanding{}
+	for _, st := range m.Standings() {
+		row := apiStanding{
+			Position:  st.Position,
+			Driver:    m.driver(st.CarID),
+			Laps:      st.Laps,
+			Completed: st.Completed,
+		}
+		if st.HasBestLap() {
+			best := st.BestLap
+			row.BestLap = &best
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// laps returns the lap history, newest first. The driver parameter selects
+// the laps of a driver by GUID or, ignoring case, by name.
+func laps(r *http.Request, m *ACServerMonitor) any {
+	driver := r.FormValue("driver")
+
+	history := m.RecentLaps()
+	laps := []LapRecord{}
+	for i := len(history) - 1; i >= 0; i-- {
+		lap := history[i]
+		if driver == "" || lap.GUID == driver || strings.EqualFold(lap.Name, driver) {
+			laps = append(laps, lap)
+		}
+	}
+	return laps
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStandingsBestLaps(t *testing.T) {
+	m := &ACServerMonitor{
+		cars: map[uint8]*CarInfo{
+			1: {CarID: 1, DriverName: "Alice"},
+			2: {CarID: 2, DriverName: "Bob"},
+			3: {CarID: 3, DriverName: "Carol"},
+		},
+		standings: []Standing{
+			{Position: 1, CarID: 1, BestLap: 85000, Laps: 3},
+			{Position: 2, CarID: 2, BestLap: 90000, Laps: 3},
+			{Position: 3, CarID: 3, BestLap: 95000, Laps: 2},
+			{Position: 4, CarID: 4, BestLap: noLapTime},
+		},
+	}
+
+	data, err := json.Marshal(standings(httptest.NewRequest("GET", "/api/standings", nil), m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []struct {
+		CarID   uint8   `json:"car_id"`
+		BestLap *uint32 `json:"best_lap_ms"`
+	}
+	if err := json.Unmarshal(data, &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[uint8]uint32{1: 85000, 2: 90000, 3: 95000}
+	if len(rows) != 4 {
+		t.Fatalf("got %d rows, want 4", len(rows))
+	}
+	for _, row := range rows {
+		best, ok := want[row.CarID]
+		switch {
+		case !ok && row.BestLap != nil:
+			t.Errorf("car %d: best_lap_ms = %d, want null", row.CarID, *row.BestLap)
+		case ok && row.BestLap == nil:
+			t.Errorf("car %d: best_lap_ms = null, want %d", row.CarID, best)
+		case ok && *row.BestLap != best:
+			t.Errorf("car %d: best_lap_ms = %d, want %d", row.CarID, *row.BestLap, best)
+		}
+	}
+}
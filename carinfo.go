@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// carInfoPoller queues ACSP_GET_CAR_INFO requests and tracks which of them the
+// server answered.
+type carInfoPoller struct {
+	interval   time.Duration // between requests
+	timeout    time.Duration // after which a request counts as unanswered
+	mu         sync.Mutex
+	queue      []uint8
+	queued     map[uint8]bool
+	pending    map[uint8]time.Time // requested, not answered yet
+	unanswered map[uint8]bool      // timed out, not answered since
+	requests   int64
+	timeouts   int64
+}
+
+func newCarInfoPoller(interval, timeout time.Duration) *carInfoPoller {
+	return &carInfoPoller{
+		interval:   interval,
+		timeout:    timeout,
+		queued:     make(map[uint8]bool),
+		pending:    make(map[uint8]time.Time),
+		unanswered: make(map[uint8]bool),
+	}
+}
+
+// enqueue schedules a request for each car slot that is not already queued.
+func (p *carInfoPoller) enqueue(carIDs ...uint8) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, carID := range carIDs {
+		if !p.queued[carID] {
+			p.queued[carID] = true
+			p.queue = append(p.queue, carID)
+		}
+	}
+}
+
+// next pops the next slot to request, if any, and marks it pending.
+func (p *carInfoPoller) next(now time.Time) (uint8, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.queue) == 0 {
+		return 0, false
+	}
+	carID := p.queue[0]
+	p.queue = p.queue[1:]
+	delete(p.queued, carID)
+	p.pending[carID] = now
+	p.requests++
+	return carID, true
+}
+
+// expire moves requests older than the timeout to the unanswered set and
+// returns their slots.
+func (p *carInfoPoller) expire(now time.Time) []uint8 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var expired []uint8
+	for carID, sent := range p.pending {
+		if now.Sub(sent) > p.timeout {
+			delete(p.pending, carID)
+			p.unanswered[carID] = true
+			p.timeouts++
+			expired = append(expired, carID)
+		}
+	}
+	return expired
+}
+
+// answered records that the server sent the info of carID.
+func (p *carInfoPoller) answered(carID uint8) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.pending, carID)
+	delete(p.unanswered, carID)
+}
+
+// forgetFrom drops the unanswered slots from slots upwards, after the server
+// shrank.
+func (p *carInfoPoller) forgetFrom(slots int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for carID := range p.unanswered {
+		if int(carID) >= slots {
+			delete(p.unanswered, carID)
+		}
+	}
+}
+
+// stats returns the request and timeout totals and the number of slots that
+// have not answered.
+func (p *carInfoPoller) stats() (requests, timeouts int64, unanswered int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests, p.timeouts, len(p.unanswered)
+}
+
+// PollCarInfo sends queued car info requests, one per configured interval,
+// and logs the slots that do not answer in time. It
+// returns when ctx is cancelled.
+func (m *ACServerMonitor) PollCarInfo(ctx context.Context) {
+	ticker := time.NewTicker(m.carInfo.interval)
+	defer ticker.Stop()
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+
+		if expired := m.carInfo.expire(now); len(expired) > 0 {
+			slots := make([]int, len(expired))
+			for i, carID := range expired {
+				slots[i] = int(carID)
+			}
+			sort.Ints(slots)
+			m.logger.Warn("car info requests unanswered", "car_ids", slots, "timeout", m.carInfo.timeout.String())
+		}
+		if carID, ok := m.carInfo.next(now); ok {
+			if err := m.RequestCarInfo(carID); err != nil {
+				m.logger.Warn("car info request failed", "car_id", carID, "err", err)
+			}
+		}
+	}
+}
+
+// sweepCarInfo queues a request for every slot of the server. It does nothing
+// until /INFO has reported the slot count.
+func (m *ACServerMonitor) sweepCarInfo() {
+	snapshot := m.Info()
+	if snapshot == nil {
+		return
+	}
+	slots := snapshot.Info.MaxClients
+	if slots > 256 {
+		slots = 256
+	}
+
+	carIDs := make([]uint8, slots)
+	for i := range carIDs {
+		carIDs[i] = uint8(i)
+	}
+	m.carInfo.forgetFrom(slots)
+	m.carInfo.enqueue(carIDs...)
+}
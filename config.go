@@ -0,0 +1,534 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the exporter configuration.
+type Config struct {
+	ListenAddress   string         `yaml:"listen_address"`
+	AdminToken      string         `yaml:"admin_token"`
+	ShutdownTimeout time.Duration  `yaml:"shutdown_timeout"` // drain time for in-flight requests
+	StatsInterval   time.Duration  `yaml:"stats_interval"`   // between console summaries
+	DisabledMetrics []string       `yaml:"disabled_metrics"` // metric groups left out of scrapes
+	LogLevel        string         `yaml:"log_level"`        // debug, info, warn or error
+	LogFormat       string         `yaml:"log_format"`       // text or json
+	Servers         []ServerConfig `yaml:"servers"`
+}
+
+// ServerConfig describes an Assetto Corsa server to monitor.
+type ServerConfig struct {
+	Name             string        `yaml:"name"`
+	Host             string        `yaml:"host"`
+	UDPPort          int           `yaml:"udp_port"`
+	PluginListen     string        `yaml:"plugin_listen"` // the server's UDP_PLUGIN_ADDRESS
+	HTTPPort         int           `yaml:"http_port"`
+	HTTPTimeout      time.Duration `yaml:"http_timeout"`
+	RealtimeInterval uint16        `yaml:"realtime_interval_ms"` // ms between car updates
+	InfoInterval     time.Duration `yaml:"info_interval"`
+	StaleAfter       time.Duration `yaml:"stale_after"` // drop cached server data older than this
+	CarInfoInterval  time.Duration `yaml:"car_info_interval"`
+	CarInfoTimeout   time.Duration `yaml:"car_info_timeout"`
+	ResultsDir       string        `yaml:"results_dir"`
+	RelayTargets     []string      `yaml:"plugin_relay"`
+}
+
+// defaultServerConfig holds the built-in server settings.
+var defaultServerConfig = ServerConfig{
+	Name:             "default",
+	Host:             "127.0.0.1",
+	UDPPort:          9600,
+	PluginListen:     "0.0.0.0:9601",
+	HTTPPort:         8081,
+	HTTPTimeout:      3 * time.Second,
+	RealtimeInterval: 1000,
+	InfoInterval:     15 * time.Second,
+	StaleAfter:       90 * time.Second,
+	CarInfoInterval:  50 * time.Millisecond,
+	CarInfoTimeout:   2 * time.Second,
+}
+
+// configFile is the layout of the configuration file. Servers are decoded
+// on top of the defaults block, so they only list what differs.
+type configFile struct {
+	ListenAddress   string        `yaml:"listen_address"`
+	AdminToken      string        `yaml:"admin_token"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+	StatsInterval   time.Duration `yaml:"stats_interval"`
+	DisabledMetrics []string      `yaml:"disabled_metrics"`
+	LogLevel        string        `yaml:"log_level"`
+	LogFormat       string        `yaml:"log_format"`
+	Defaults        yaml.Node     `yaml:"defaults"`
+	Servers         []yaml.Node   `yaml:"servers"`
+}
+
+// LoadConfig builds the configuration from, in increasing precedence, the
+// built-in defaults, the configuration file, the environment and the
+// command line flags in args. It also reports whether -check-config was
+// given.
+//
+// The file's defaults block, the single-server variables (AC_SERVER_HOST,
+// AC_REALTIME_INTERVAL_MS, ...) and the -ac.* flags set the defaults of
+// every server. Each server is then configured by its entry in the file's
+// servers list and by AC_SERVER_<NAME>_HOST, _UDP_PORT, ..., where <NAME> is
+// the upper-cased name with anything but letters and digits replaced by
+// "_". A named server never inherits the default plugin relay, since a
+// downstream plugin can only follow one server.
+//
+// AC_SERVERS, a comma-separated list of server names, replaces the file's
+// server list, keeping the file entries of the names it lists. Without
+// either, a single server named "default" is configured from the defaults.
+func LoadConfig(args []string) (*Config, bool, error) {
+	fs := flag.NewFlagSet("acserver-exporter", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("AC_CONFIG_FILE"), "Path to the YAML configuration file")
+	checkConfig := fs.Bool("check-config", false, "Print the effective configuration and exit")
+	listenAddress := fs.String("web.listen-address", "", "Address to serve metrics on (default \":9090\")")
+	shutdownTimeout := fs.Duration("web.shutdown-timeout", 0, "How long in-flight requests may take to drain on shutdown (default 10s)")
+	statsInterval := fs.Duration("stats-interval", 0, "Interval between console summaries (default 30s)")
+	logLevel := fs.String("log.level", "", "Log level: debug, info, warn or error (default info)")
+	logFormat := fs.String("log.format", "", "Log format: text or json (default text)")
+	disabledMetrics := fs.String("metrics.disable", "", "Comma-separated metric groups to leave out of scrapes ("+strings.Join(metricGroups, ", ")+")")
+	host := fs.String("ac.host", "", "Default server IP/hostname")
+	udpPort := fs.Int("ac.udp-port", 0, "Default server UDP plugin port")
+	pluginListen := fs.String("ac.plugin-listen", "", "Default local address the server sends plugin traffic to (default \"0.0.0.0:9601\")")
+	httpPort := fs.Int("ac.http-port", 0, "Default server HTTP API port")
+	httpTimeout := fs.Duration("ac.http-timeout", 0, "Default timeout of /INFO requests")
+	realtimeInterval := fs.Uint("ac.realtime-interval-ms", 0, "Default realtime car update interval (ms, 0 disables)")
+	infoInterval := fs.Duration("ac.info-interval", 0, "Default /INFO poll interval")
+	staleAfter := fs.Duration("ac.stale-after", 0, "Default staleness threshold for cached server data")
+	carInfoInterval := fs.Duration("ac.car-info-interval", 0, "Default minimum interval between car info requests")
+	carInfoTimeout := fs.Duration("ac.car-info-timeout", 0, "Default time a car info request may go unanswered")
+	resultsDir := fs.String("ac.results-dir", "", "Default session results directory")
+	if err := fs.Parse(args); err != nil {
+		return nil, false, err
+	}
+	if fs.NArg() > 0 {
+		return nil, false, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg := &Config{
+		ListenAddress:   ":9090",
+		ShutdownTimeout: 10 * time.Second,
+		StatsInterval:   30 * time.Second,
+		LogLevel:        "info",
+		LogFormat:       "text",
+	}
+	defaults := defaultServerConfig
+
+	var file configFile
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read config file: %v", err)
+		}
+		if err := decodeStrict(data, &file); err != nil {
+			return nil, false, fmt.Errorf("failed to parse config file %s: %v", *configPath, err)
+		}
+		if file.ListenAddress != "" {
+			cfg.ListenAddress = file.ListenAddress
+		}
+		cfg.AdminToken = file.AdminToken
+		if file.ShutdownTimeout != 0 {
+			cfg.ShutdownTimeout = file.ShutdownTimeout
+		}
+		if file.StatsInterval != 0 {
+			cfg.StatsInterval = file.StatsInterval
+		}
+		cfg.DisabledMetrics = file.DisabledMetrics
+		if file.LogLevel != "" {
+			cfg.LogLevel = file.LogLevel
+		}
+		if file.LogFormat != "" {
+			cfg.LogFormat = file.LogFormat
+		}
+		if err := decodeServer(&file.Defaults, &defaults); err != nil {
+			return nil, false, fmt.Errorf("invalid defaults in %s: %v", *configPath, err)
+		}
+	}
+
+	env := &envReader{}
+	if port := os.Getenv("METRICS_PORT"); port != "" {
+		cfg.ListenAddress = ":" + port
+	}
+	env.string("ADMIN_TOKEN", &cfg.AdminToken)
+	env.string("LOG_LEVEL", &cfg.LogLevel)
+	env.string("LOG_FORMAT", &cfg.LogFormat)
+	if disabled := os.Getenv("AC_DISABLED_METRICS"); disabled != "" {
+		cfg.DisabledMetrics = splitList(disabled)
+	}
+	env.serverConfig("AC_", &defaults)
+	if env.err != nil {
+		return nil, false, env.err
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "web.listen-address":
+			cfg.ListenAddress = *listenAddress
+		case "web.shutdown-timeout":
+			cfg.ShutdownTimeout = *shutdownTimeout
+		case "stats-interval":
+			cfg.StatsInterval = *statsInterval
+		case "log.level":
+			cfg.LogLevel = *logLevel
+		case "log.format":
+			cfg.LogFormat = *logFormat
+		case "metrics.disable":
+			cfg.DisabledMetrics = splitList(*disabledMetrics)
+		case "ac.host":
+			defaults.Host = *host
+		case "ac.udp-port":
+			defaults.UDPPort = *udpPort
+		case "ac.plugin-listen":
+			defaults.PluginListen = *pluginListen
+		case "ac.http-port":
+			defaults.HTTPPort = *httpPort
+		case "ac.http-timeout":
+			defaults.HTTPTimeout = *httpTimeout
+		case "ac.realtime-interval-ms":
+			if *realtimeInterval > 65535 {
+				flagErr = fmt.Errorf("-ac.realtime-interval-ms must be at most 65535")
+			}
+			defaults.RealtimeInterval = uint16(*realtimeInterval)
+		case "ac.info-interval":
+			defaults.InfoInterval = *infoInterval
+		case "ac.stale-after":
+			defaults.StaleAfter = *staleAfter
+		case "ac.car-info-interval":
+			defaults.CarInfoInterval = *carInfoInterval
+		case "ac.car-info-timeout":
+			defaults.CarInfoTimeout = *carInfoTimeout
+		case "ac.results-dir":
+			defaults.ResultsDir = *resultsDir
+		}
+	})
+	if flagErr != nil {
+		return nil, false, flagErr
+	}
+
+	servers, err := loadServers(&file, defaults)
+	if err != nil {
+		return nil, false, err
+	}
+	cfg.Servers = servers
+
+	if err := cfg.Validate(); err != nil {
+		return nil, false, err
+	}
+	return cfg, *checkConfig, nil
+}
+
+// loadServers configures the servers listed in AC_SERVERS or in the file.
+func loadServers(file *configFile, defaults ServerConfig) ([]ServerConfig, error) {
+	entries := make(map[string]*yaml.Node)
+	var names []string
+	for i := range file.Servers {
+		var entry struct {
+			Name string `yaml:"name"`
+		}
+		if err := file.Servers[i].Decode(&entry); err != nil {
+			return nil, fmt.Errorf("invalid server entry: %v", err)
+		}
+		if entry.Name == "" {
+			return nil, fmt.Errorf("server entry at line %d has no name", file.Servers[i].Line)
+		}
+		if entries[entry.Name] != nil {
+			return nil, fmt.Errorf("server %q is declared more than once", entry.Name)
+		}
+		entries[entry.Name] = &file.Servers[i]
+		names = append(names, entry.Name)
+	}
+
+	if list := splitList(os.Getenv("AC_SERVERS")); len(list) > 0 {
+		names = list
+	}
+	if len(names) == 0 {
+		return []ServerConfig{defaults}, nil
+	}
+
+	named := defaults
+	named.RelayTargets = nil
+
+	seen := make(map[string]bool)
+	servers := make([]ServerConfig, 0, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return nil, fmt.Errorf("server %q is declared more than once in AC_SERVERS", name)
+		}
+		seen[name] = true
+
+		cfg := named
+		if entry := entries[name]; entry != nil {
+			if err := decodeServer(entry, &cfg); err != nil {
+				return nil, fmt.Errorf("invalid server %q: %v", name, err)
+			}
+		}
+		cfg.Name = name
+
+		env := &envReader{}
+		env.serverConfig("AC_SERVER_"+envName(name)+"_", &cfg)
+		if env.err != nil {
+			return nil, env.err
+		}
+		servers = append(servers, cfg)
+	}
+	return servers, nil
+}
+
+// serverConfigKeys are the settings a server block may hold.
+var serverConfigKeys = func() map[string]bool {
+	keys := make(map[string]bool)
+	t := reflect.TypeOf(ServerConfig{})
+	for i := 0; i < t.NumField(); i++ {
+		keys[t.Field(i).Tag.Get("yaml")] = true
+	}
+	return keys
+}()
+
+// decodeServer decodes a server block on top of cfg, keeping the settings
+// it does not mention.
+func decodeServer(node *yaml.Node, cfg *ServerConfig) error {
+	if node.Kind == 0 {
+		return nil
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			if key := node.Content[i]; !serverConfigKeys[key.Value] {
+				return fmt.Errorf("line %d: unknown setting %q", key.Line, key.Value)
+			}
+		}
+	}
+	return node.Decode(cfg)
+}
+
+// decodeStrict decodes YAML, rejecting unknown keys so that typos do not go
+// unnoticed.
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Validate reports the first invalid setting.
+func (c *Config) Validate() error {
+	if !validAddress(c.ListenAddress) {
+		return fmt.Errorf("listen_address %q must be [host]:port with a port from 1 to 65535", c.ListenAddress)
+	}
+	if c.ShutdownTimeout <= 0 {
+		return fmt.Errorf("shutdown_timeout must be positive")
+	}
+	if c.StatsInterval <= 0 {
+		return fmt.Errorf("stats_interval must be positive")
+	}
+	if _, err := parseLogLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("log_level: %v", err)
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("log_format must be text or json")
+	}
+	for _, group := range c.DisabledMetrics {
+		if !slices.Contains(metricGroups, group) {
+			return fmt.Errorf("disabled_metrics: unknown metric group %q (known groups: %s)", group, strings.Join(metricGroups, ", "))
+		}
+	}
+	listeners := make(map[int]string)
+	for _, s := range c.Servers {
+		if err := s.Validate(); err != nil {
+			return fmt.Errorf("server %q: %v", s.Name, err)
+		}
+		// Each server needs a plugin address of its own
+		port := addressPort(s.PluginListen)
+		if other, ok := listeners[port]; ok {
+			return fmt.Errorf("servers %q and %q both listen for plugin traffic on port %d; set plugin_listen for each server", other, s.Name, port)
+		}
+		listeners[port] = s.Name
+	}
+	return nil
+}
+
+// Validate reports the first invalid setting of the server.
+func (s *ServerConfig) Validate() error {
+	if s.Host == "" {
+		return fmt.Errorf("host must not be empty")
+	}
+	if s.UDPPort < 1 || s.UDPPort > 65535 {
+		return fmt.Errorf("udp_port %d is not a valid port", s.UDPPort)
+	}
+	if !validAddress(s.PluginListen) {
+		return fmt.Errorf("plugin_listen %q must be [host]:port with a port from 1 to 65535", s.PluginListen)
+	}
+	if s.HTTPPort < 1 || s.HTTPPort > 65535 {
+		return fmt.Errorf("http_port %d is not a valid port", s.HTTPPort)
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"http_timeout", s.HTTPTimeout},
+		{"info_interval", s.InfoInterval},
+		{"stale_after", s.StaleAfter},
+		{"car_info_interval", s.CarInfoInterval},
+		{"car_info_timeout", s.CarInfoTimeout},
+	} {
+		if d.value <= 0 {
+			return fmt.Errorf("%s must be positive", d.name)
+		}
+	}
+	// Every /INFO snapshot would be dropped as stale before the next poll.
+	if s.StaleAfter < s.InfoInterval {
+		return fmt.Errorf("stale_after %s must not be shorter than info_interval %s", s.StaleAfter, s.InfoInterval)
+	}
+	for _, target := range s.RelayTargets {
+		if !validAddress(target) {
+			return fmt.Errorf("plugin relay target %q must be host:port with a port from 1 to 65535", target)
+		}
+	}
+	return nil
+}
+
+// validAddress reports whether addr is [host]:port with a port from 1 to
+// 65535.
+func validAddress(addr string) bool {
+	port := addressPort(addr)
+	return port >= 1 && port <= 65535
+}
+
+// addressPort returns the port of a [host]:port address, or -1 if it has
+// none.
+func addressPort(addr string) int {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return -1
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// String renders the configuration as YAML, with the admin token redacted.
+func (c *Config) String() string {
+	redacted := *c
+	if redacted.AdminToken != "" {
+		redacted.AdminToken = "<redacted>"
+	}
+	data, err := yaml.Marshal(&redacted)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// envReader overrides settings from environment variables, recording the
+// first value that does not parse.
+type envReader struct {
+	err error
+}
+
+// serverConfig reads server settings from the variables starting with
+// prefix. The single-server variables (prefix "AC_") keep their historical
+// AC_SERVER_HOST, AC_SERVER_UDP_PORT, ... names for the connection settings.
+func (e *envReader) serverConfig(prefix string, cfg *ServerConfig) {
+	connPrefix := prefix
+	if prefix == "AC_" {
+		connPrefix = "AC_SERVER_"
+	}
+	e.string(connPrefix+"HOST", &cfg.Host)
+	e.int(connPrefix+"UDP_PORT", &cfg.UDPPort)
+	e.string(prefix+"PLUGIN_LISTEN", &cfg.PluginListen)
+	e.int(connPrefix+"HTTP_PORT", &cfg.HTTPPort)
+	e.duration(connPrefix+"HTTP_TIMEOUT", &cfg.HTTPTimeout)
+	e.uint16(prefix+"REALTIME_INTERVAL_MS", &cfg.RealtimeInterval)
+	e.duration(prefix+"INFO_INTERVAL", &cfg.InfoInterval)
+	e.duration(prefix+"STALE_AFTER", &cfg.StaleAfter)
+	e.duration(prefix+"CAR_INFO_INTERVAL", &cfg.CarInfoInterval)
+	e.duration(prefix+"CAR_INFO_TIMEOUT", &cfg.CarInfoTimeout)
+	e.string(prefix+"RESULTS_DIR", &cfg.ResultsDir)
+	if relay := os.Getenv(prefix + "PLUGIN_RELAY"); relay != "" {
+		cfg.RelayTargets = splitList(relay)
+	}
+}
+
+func (e *envReader) string(key string, dst *string) {
+	if value := os.Getenv(key); value != "" {
+		*dst = value
+	}
+}
+
+func (e *envReader) int(key string, dst *int) {
+	if value := os.Getenv(key); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			e.fail(key, value)
+			return
+		}
+		*dst = n
+	}
+}
+
+func (e *envReader) uint16(key string, dst *uint16) {
+	if value := os.Getenv(key); value != "" {
+		n, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			e.fail(key, value)
+			return
+		}
+		*dst = uint16(n)
+	}
+}
+
+// duration reads a duration such as "15s".
+func (e *envReader) duration(key string, dst *time.Duration) {
+	if value := os.Getenv(key); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			e.fail(key, value)
+			return
+		}
+		*dst = d
+	}
+}
+
+func (e *envReader) fail(key, value string) {
+	if e.err == nil {
+		e.err = fmt.Errorf("%s: invalid value %q", key, value)
+	}
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// envName turns a server name into the form used in environment variable
+// names.
+func envName(name string) string {
+	return nonAlphanumeric.ReplaceAllString(strings.ToUpper(name), "_")
+}
+
+// splitList splits a comma-separated list, dropping empty entries.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a configuration file and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+listen_address: ":1000"
+log_level: debug
+defaults:
+  host: 10.0.0.1
+  udp_port: 9601
+  http_port: 8082
+  info_interval: 20s
+`)
+
+	t.Run("file", func(t *testing.T) {
+		cfg, _, err := LoadConfig([]string{"-config", path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := cfg.Servers[0]
+		if cfg.ListenAddress != ":1000" || cfg.LogLevel != "debug" || s.Host != "10.0.0.1" || s.UDPPort != 9601 || s.HTTPPort != 8082 {
+			t.Errorf("file settings not applied: %+v", cfg)
+		}
+		if s.InfoInterval != 20*time.Second || s.StaleAfter != defaultServerConfig.StaleAfter {
+			t.Errorf("info_interval = %s, stale_after = %s", s.InfoInterval, s.StaleAfter)
+		}
+	})
+
+	t.Run("env over file", func(t *testing.T) {
+		t.Setenv("METRICS_PORT", "2000")
+		t.Setenv("AC_SERVER_HOST", "10.0.0.2")
+		t.Setenv("AC_SERVER_UDP_PORT", "9602")
+		cfg, _, err := LoadConfig([]string{"-config", path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := cfg.Servers[0]
+		if cfg.ListenAddress != ":2000" || s.Host != "10.0.0.2" || s.UDPPort != 9602 || s.HTTPPort != 8082 {
+			t.Errorf("env settings not applied over file: %+v", cfg)
+		}
+	})
+
+	t.Run("flags over env", func(t *testing.T) {
+		t.Setenv("METRICS_PORT", "2000")
+		t.Setenv("AC_SERVER_HOST", "10.0.0.2")
+		cfg, checkConfig, err := LoadConfig([]string{
+			"-config", path,
+			"-web.listen-address", "127.0.0.1:3000",
+			"-ac.host", "10.0.0.3",
+			"-check-config",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := cfg.Servers[0]
+		if cfg.ListenAddress != "127.0.0.1:3000" || s.Host != "10.0.0.3" || s.UDPPort != 9601 {
+			t.Errorf("flag settings not applied over env: %+v", cfg)
+		}
+		if !checkConfig {
+			t.Error("-check-config not reported")
+		}
+	})
+}
+
+func TestLoadConfigServers(t *testing.T) {
+	path := writeConfig(t, `
+defaults:
+  http_port: 8090
+  plugin_relay: [127.0.0.1:12001]
+servers:
+  - name: eu-gt3
+    host: 10.0.0.10
+    plugin_listen: 0.0.0.0:12000
+  - name: eu-drift
+    host: 10.0.0.11
+    plugin_listen: 0.0.0.0:12010
+`)
+
+	t.Run("file", func(t *testing.T) {
+		cfg, _, err := LoadConfig([]string{"-config", path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfg.Servers) != 2 || cfg.Servers[0].Name != "eu-gt3" || cfg.Servers[1].Name != "eu-drift" {
+			t.Fatalf("servers = %+v", cfg.Servers)
+		}
+		for _, s := range cfg.Servers {
+			if s.HTTPPort != 8090 {
+				t.Errorf("%s: http_port = %d, want the default 8090", s.Name, s.HTTPPort)
+			}
+			if len(s.RelayTargets) != 0 {
+				t.Errorf("%s: inherited the default plugin relay", s.Name)
+			}
+		}
+	})
+
+	t.Run("AC_SERVERS", func(t *testing.T) {
+		t.Setenv("AC_SERVERS", "eu-drift, us-gt3")
+		t.Setenv("AC_SERVER_EU_DRIFT_UDP_PORT", "12000")
+		t.Setenv("AC_SERVER_US_GT3_HOST", "10.0.1.10")
+		t.Setenv("AC_SERVER_US_GT3_PLUGIN_LISTEN", "127.0.0.1:12020")
+		cfg, _, err := LoadConfig([]string{"-config", path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cfg.Servers) != 2 {
+			t.Fatalf("got %d servers, want 2", len(cfg.Servers))
+		}
+		drift, us := cfg.Servers[0], cfg.Servers[1]
+		if drift.Name != "eu-drift" || drift.Host != "10.0.0.11" || drift.UDPPort != 12000 {
+			t.Errorf("eu-drift = %+v, want its file entry with the env port", drift)
+		}
+		if us.Name != "us-gt3" || us.Host != "10.0.1.10" || us.PluginListen != "127.0.0.1:12020" || us.HTTPPort != 8090 {
+			t.Errorf("us-gt3 = %+v, want the defaults with the env host", us)
+		}
+	})
+
+	t.Run("duplicate in AC_SERVERS", func(t *testing.T) {
+		t.Setenv("AC_SERVERS", "eu-gt3,eu-gt3")
+		if _, _, err := LoadConfig([]string{"-config", path}); err == nil {
+			t.Error("duplicate server accepted")
+		}
+	})
+
+	t.Run("shared plugin port", func(t *testing.T) {
+		t.Setenv("AC_SERVER_EU_DRIFT_PLUGIN_LISTEN", "127.0.0.1:12000")
+		_, _, err := LoadConfig([]string{"-config", path})
+		if err == nil || !strings.Contains(err.Error(), "plugin_listen") {
+			t.Errorf("error = %v, want servers sharing a plugin port rejected", err)
+		}
+	})
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{
+			name: "non-numeric METRICS_PORT",
+			env:  map[string]string{"METRICS_PORT": "abc"},
+			want: "listen_address",
+		},
+		{
+			name: "listen port out of range",
+			args: []string{"-web.listen-address", ":99999"},
+			want: "listen_address",
+		},
+		{
+			name: "listen port zero",
+			args: []string{"-web.listen-address", ":0"},
+			want: "listen_address",
+		},
+		{
+			name: "plugin_listen without port",
+			env:  map[string]string{"AC_PLUGIN_LISTEN": "0.0.0.0"},
+			want: "plugin_listen",
+		},
+		{
+			name: "plugin_listen port out of range",
+			args: []string{"-ac.plugin-listen", "0.0.0.0:65536"},
+			want: "plugin_listen",
+		},
+		{
+			name: "stale_after shorter than info_interval",
+			env:  map[string]string{"AC_STALE_AFTER": "1s", "AC_INFO_INTERVAL": "1m"},
+			want: "stale_after",
+		},
+		{
+			name: "unparsable duration",
+			env:  map[string]string{"AC_INFO_INTERVAL": "soon"},
+			want: "AC_INFO_INTERVAL",
+		},
+		{
+			name: "relay target port",
+			env:  map[string]string{"AC_PLUGIN_RELAY": "127.0.0.1:70000"},
+			want: "plugin relay target",
+		},
+		{
+			name: "unknown metric group",
+			args: []string{"-metrics.disable", "telemetry"},
+			want: "disabled_metrics",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, _, err := LoadConfig(tt.args)
+			if err == nil {
+				t.Fatal("invalid configuration accepted")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
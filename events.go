@@ -0,0 +1,235 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"acserver-exporter/acsp"
+)
+
+// EventType names a protocol event published on the bus.
+type EventType string
+
+const (
+	EventNewSession       EventType = "new_session"
+	EventSessionInfo      EventType = "session_info"
+	EventEndSession       EventType = "end_session"
+	EventNewConnection    EventType = "new_connection"
+	EventConnectionClosed EventType = "connection_closed"
+	EventClientLoaded     EventType = "client_loaded"
+	EventCarInfo          EventType = "car_info"
+	EventLapCompleted     EventType = "lap_completed"
+	EventCollision        EventType = "collision"
+	EventChat             EventType = "chat"
+	EventVersion          EventType = "version"
+	EventError            EventType = "error"
+)
+
+// Event is a decoded protocol event. Data holds the payload matching Type:
+// *SessionEvent, *EndSessionEvent, *ConnectionEvent, *CarInfoEvent,
+// *LapEvent, *CollisionEvent, *ChatEvent, *VersionEvent or *ErrorEvent.
+// Events are shared between subscribers and must not be modified.
+type Event struct {
+	Server string    `json:"server"`
+	Type   EventType `json:"type"`
+	Time   time.Time `json:"time"`
+	Data   any       `json:"data"`
+}
+
+// Driver identifies the driver of a car slot as known to the monitor when
+// the event was received.
+type Driver struct {
+	CarID    uint8  `json:"car_id"`
+	Name     string `json:"driver"`
+	GUID     string `json:"guid"`
+	CarModel string `json:"car_model"`
+
+	placeholder bool // Name stands in for an unknown driver
+}
+
+// Known reports whether the driver is known by name or GUID rather than
+// only by car slot.
+func (d *Driver) Known() bool {
+	return !d.placeholder
+}
+
+// SessionEvent is published for new_session and session_info.
+type SessionEvent struct {
+	ServerName  string `json:"server_name"`
+	SessionName string `json:"session_name"`
+	SessionType string `json:"session_type"`
+	Track       string `json:"track"`
+	TrackConfig string `json:"track_config"`
+	Laps        uint16 `json:"laps"`
+	TimeMinutes uint16 `json:"time_minutes"`
+}
+
+// EndSessionEvent is published for end_session.
+type EndSessionEvent struct {
+	SessionType string `json:"session_type"`
+	ReportFile  string `json:"report_file"`
+}
+
+// ConnectionEvent is published for new_connection, connection_closed and
+// client_loaded.
+type ConnectionEvent struct {
+	Driver
+	CarSkin string `json:"car_skin,omitempty"`
+}
+
+// CarInfoEvent is published for car_info.
+type CarInfoEvent struct {
+	Driver
+	CarSkin   string `json:"car_skin"`
+	Connected bool   `json:"connected"`
+}
+
+// LapEvent is published for lap_completed.
+type LapEvent struct {
+	Driver
+	Track     string  `json:"track"`
+	Lap       int     `json:"lap"`    // 0 if the car is missing from the leaderboard
+	LapTime   uint32  `json:"lap_ms"` // ms
+	Cuts      uint8   `json:"cuts"`
+	GripLevel float32 `json:"grip_level"`
+}
+
+// CollisionEvent is published for collision. Other is only set for contacts
+// with another car.
+type CollisionEvent struct {
+	Driver
+	With        acsp.ClientEventType `json:"with"`
+	ImpactSpeed float32              `json:"impact_speed_kmh"` // km/h
+	Lap         int                  `json:"lap,omitempty"`    // 0 before the first completed lap
+	Other       *Driver              `json:"other,omitempty"`
+}
+
+// ChatEvent is published for chat.
+type ChatEvent struct {
+	Driver
+	Message string `json:"message"`
+}
+
+// VersionEvent is published for version.
+type VersionEvent struct {
+	Version uint8 `json:"version"`
+}
+
+// ErrorEvent is published for error.
+type ErrorEvent struct {
+	Message string `json:"message"`
+}
+
+// EventBus fans events out to its subscribers. Every subscription has its own
+// bounded queue; when it is full the event is dropped for that subscriber
+// only and counted, so a slow subscriber never blocks the publisher.
+type EventBus struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]bool
+	totals map[string]*subscriberTotals // by subscriber name, kept after unsubscribing
+}
+
+// subscriberTotals counts the events handed to the subscribers of a name.
+type subscriberTotals struct {
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+}
+
+// Subscription is a subscriber's queue of events.
+type Subscription struct {
+	bus    *EventBus
+	name   string
+	filter func(Event) bool
+	events chan Event
+	once   sync.Once
+}
+
+// SubscriberStats are the queue statistics of the subscribers sharing a name.
+type SubscriberStats struct {
+	Name          string
+	Subscriptions int
+	Queued        int
+	Delivered     uint64
+	Dropped       uint64
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subs:   make(map[*Subscription]bool),
+		totals: make(map[string]*subscriberTotals),
+	}
+}
+
+// Subscribe returns a subscription receiving the events published from now
+// on for which filter returns true, or all of them if filter is nil, queueing
+// up to size of them. Subscriptions of the same name share their counters.
+func (b *EventBus) Subscribe(name string, size int, filter func(Event) bool) *Subscription {
+	sub := &Subscription{bus: b, name: name, filter: filter, events: make(chan Event, size)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[sub] = true
+	if b.totals[name] == nil {
+		b.totals[name] = &subscriberTotals{}
+	}
+	return sub
+}
+
+// Publish queues e for every subscriber without blocking.
+func (b *EventBus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+		totals := b.totals[sub.name]
+		select {
+		case sub.events <- e:
+			totals.delivered.Add(1)
+		default:
+			totals.dropped.Add(1)
+		}
+	}
+}
+
+// Stats returns the statistics of every subscriber name, sorted by name.
+func (b *EventBus) Stats() []SubscriberStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stats := make(map[string]*SubscriberStats, len(b.totals))
+	for name, totals := range b.totals {
+		stats[name] = &SubscriberStats{Name: name, Delivered: totals.delivered.Load(), Dropped: totals.dropped.Load()}
+	}
+	for sub := range b.subs {
+		stats[sub.name].Subscriptions++
+		stats[sub.name].Queued += len(sub.events)
+	}
+
+	result := make([]SubscriberStats, 0, len(stats))
+	for _, s := range stats {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Events returns the subscription's queue. It is closed by Close.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close unsubscribes and closes the queue. Events still queued can be
+// drained.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mu.Lock()
+		delete(s.bus.subs, s)
+		s.bus.mu.Unlock()
+		close(s.events)
+	})
+}
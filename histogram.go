@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Bucket upper bounds for lap times (seconds), wide enough for everything
+// from short club circuits to the Nordschleife.
+var lapTimeBuckets = []float64{30, 45, 60, 75, 90, 105, 120, 150, 180, 240, 300, 420, 600}
+
+// Bucket upper bounds for collision impact speeds (km/h).
+var impactSpeedBuckets = []float64{5, 10, 20, 30, 50, 75, 100, 150, 200}
+
+// histogram is a cumulative Prometheus-style histogram. It is not safe for
+// concurrent use; callers guard it with the monitor's metricsLock.
+type histogram struct {
+	upperBounds []float64
+	counts      []uint64 // per bucket, not cumulative
+	count       uint64
+	sum         float64
+	exemplars   []*prometheus.Exemplar // latest per bucket, the last one for +Inf
+}
+
+// exemplarLabels returns the exemplar labels of an observation made for a
+// driver on a lap, 0 meaning unknown. The GUID comes from the network and is
+// truncated so that the labels stay within the OpenMetrics limit of
+// prometheus.ExemplarMaxRunes.
+func exemplarLabels(guid string, lap int) prometheus.Labels {
+	labels := prometheus.Labels{}
+	budget := prometheus.ExemplarMaxRunes - len("guid")
+	if lap > 0 {
+		labels["lap"] = strconv.Itoa(lap)
+		budget -= len("lap") + len(labels["lap"])
+	}
+	if utf8.RuneCountInString(guid) > budget {
+		guid = string([]rune(guid)[:budget])
+	}
+	labels["guid"] = guid
+	return labels
+}
+
+func newHistogram(upperBounds []float64) *histogram {
+	return &histogram{
+		upperBounds: upperBounds,
+		counts:      make([]uint64, len(upperBounds)),
+		exemplars:   make([]*prometheus.Exemplar, len(upperBounds)+1),
+	}
+}
+
+// ObserveWithExemplar records v and keeps it, with labels, as the exemplar of
+// its bucket.
+func (h *histogram) ObserveWithExemplar(v float64, labels prometheus.Labels) {
+	bucket := h.observe(v)
+	h.exemplars[bucket] = &prometheus.Exemplar{Value: v, Labels: labels, Timestamp: time.Now()}
+}
+
+// observe records v and returns the index of its bucket, len(upperBounds)
+// being the +Inf bucket.
+func (h *histogram) observe(v float64) int {
+	bucket := len(h.upperBounds)
+	for i, bound := range h.upperBounds {
+		if v <= bound {
+			h.counts[i]++
+			bucket = i
+			break
+		}
+	}
+	h.count++
+	h.sum += v
+	return bucket
+}
+
+// cumulativeCounts returns the cumulative count of observations for each
+// bucket upper bound, as expected by prometheus.NewConstHistogram.
+func (h *histogram) cumulativeCounts() map[float64]uint64 {
+	buckets := make(map[float64]uint64, len(h.upperBounds))
+	var cumulative uint64
+	for i, bound := range h.upperBounds {
+		cumulative += h.counts[i]
+		buckets[bound] = cumulative
+	}
+	return buckets
+}
+
+// metric returns the histogram as a constant metric, with its exemplars
+// attached.
+func (h *histogram) metric(desc *prometheus.Desc, labelValues ...string) prometheus.Metric {
+	m := prometheus.MustNewConstHistogram(desc, h.count, h.sum, h.cumulativeCounts(), labelValues...)
+
+	var exemplars []prometheus.Exemplar
+	for _, e := range h.exemplars {
+		if e != nil {
+			exemplars = append(exemplars, *e)
+		}
+	}
+	if len(exemplars) == 0 {
+		return m
+	}
+	if withExemplars, err := prometheus.NewMetricWithExemplars(m, exemplars...); err == nil {
+		return withExemplars
+	}
+	return m
+}
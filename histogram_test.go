@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestExemplarLabelsOversizedGUID(t *testing.T) {
+	guid := strings.Repeat("7", 130)
+	labels := exemplarLabels(guid, 12)
+
+	runes := 0
+	for name, value := range labels {
+		runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+	}
+	if runes > prometheus.ExemplarMaxRunes {
+		t.Errorf("exemplar labels have %d runes, want at most %d", runes, prometheus.ExemplarMaxRunes)
+	}
+	if labels["lap"] != "12" {
+		t.Errorf("lap = %q, want 12", labels["lap"])
+	}
+	if !strings.HasPrefix(guid, labels["guid"]) || labels["guid"] == "" {
+		t.Errorf("guid = %q, want a prefix of the GUID", labels["guid"])
+	}
+
+	h := newHistogram(lapTimeBuckets)
+	h.ObserveWithExemplar(91.2, labels)
+	var m dto.Metric
+	if err := h.metric(lapTimeDesc, "server", "guid", "driver", "car", "track").Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Histogram.GetSampleCount() != 1 {
+		t.Errorf("sample count = %d, want 1", m.Histogram.GetSampleCount())
+	}
+}
+
+func TestHistogramMetricDropsInvalidExemplars(t *testing.T) {
+	h := newHistogram(impactSpeedBuckets)
+	h.ObserveWithExemplar(42, prometheus.Labels{"guid": strings.Repeat("7", 130)})
+
+	var m dto.Metric
+	if err := h.metric(impactSpeedDesc, "server").Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Histogram.GetSampleCount() != 1 {
+		t.Errorf("sample count = %d, want 1", m.Histogram.GetSampleCount())
+	}
+	for _, bucket := range m.Histogram.Bucket {
+		if bucket.Exemplar != nil {
+			t.Errorf("bucket %v has an exemplar, want none", bucket.GetUpperBound())
+		}
+	}
+}
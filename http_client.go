@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -8,29 +9,63 @@ import (
 	"time"
 )
 
-func FetchHTTPInfo(m *ACServerMonitor) error {
-	url := fmt.Sprintf("http://%s:%d/INFO", m.httpHost, m.httpPort)
+// FetchHTTPInfo polls the /INFO endpoint of m's server and publishes the
+// result as m's latest snapshot. A failed poll keeps the previous snapshot.
+func FetchHTTPInfo(ctx context.Context, m *ACServerMonitor) error {
+	start := time.Now()
+	info, err := FetchServerInfo(ctx, fmt.Sprintf("%s:%d", m.httpHost, m.httpPort), m.httpTimeout)
 	
-	client := &http.Client{Timeout: 3 * time.Second}
-	resp, err := client.Get(url)
+	m.metricsLock.Lock()
+	m.infoFetches++
+	m.infoFetchDuration = time.Since(start)
 	if err != nil {
-		return fmt.Errorf("HTTP API request failed: %v", err)
+		m.infoFetchErrors++
+	}
+	m.metricsLock.Unlock()
+	
+	m.mu.Lock()
+	previous := m.info
+	m.infoUp = err == nil
+	if err == nil {
+		m.info = &InfoSnapshot{Info: info, FetchedAt: time.Now()}
+	}
+	m.mu.Unlock()
+	
+	// Learn the car table once the slot count is known, and again whenever
+	// it changes
+	if err == nil && (previous == nil || previous.Info.MaxClients != info.MaxClients) {
+		m.sweepCarInfo()
+	}
+	
+	return err
+}
+
+// FetchServerInfo queries the /INFO endpoint of the AC server HTTP API at
+// address (host:port).
+func FetchServerInfo(ctx context.Context, address string, timeout time.Duration) (*ServerInfo, error) {
+	url := fmt.Sprintf("http://%s/INFO", address)
+	
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP API address: %v", err)
+	}
+	
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP API request failed: %v", err)
 	}
 	defer resp.Body.Close()
 	
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to read response: %v", err)
+		return nil, fmt.Errorf("failed to read response: %v", err)
 	}
 	
 	var info ServerInfo
 	if err := json.Unmarshal(body, &info); err != nil {
-		return fmt.Errorf("failed to parse JSON: %v", err)
+		return nil, fmt.Errorf("failed to parse JSON: %v", err)
 	}
 	
-	m.mu.Lock()
-	m.serverInfo = &info
-	m.mu.Unlock()
-	
-	return nil
+	return &info, nil
 }
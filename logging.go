@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+)
+
+// logLevel is shared by every logger, so that a reload can change it.
+var logLevel = new(slog.LevelVar)
+
+// setupLogging makes the default logger write records to w in format, which
+// is "text" or "json". The standard log package is routed through it too.
+func setupLogging(w io.Writer, format string) error {
+	opts := &slog.HandlerOptions{Level: logLevel}
+
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// parseLogLevel parses debug, info, warn or error.
+func parseLogLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return l, fmt.Errorf("unknown log level %q", level)
+	}
+	return l, nil
+}
+
+// fatal logs an error and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// round32 rounds v to the given number of decimals, so that records do not
+// show float32 conversion noise such as 0.9800000190734863.
+func round32(v float32, decimals int) float64 {
+	scale := math.Pow10(decimals)
+	return math.Round(float64(v)*scale) / scale
+}
+
+// logQueueSize is the number of events the event log may lag behind before
+// events are dropped.
+const logQueueSize = 1024
+
+// LogEvents writes the events of sub as structured records until sub is
+// closed. Records carry the server name and the event type along with the
+// event's attributes.
+func LogEvents(sub *Subscription) {
+	for e := range sub.Events() {
+		logEvent(slog.Default().With("server", e.Server, "type", string(e.Type)), e)
+	}
+}
+
+func logEvent(logger *slog.Logger, e Event) {
+	switch data := e.Data.(type) {
+	case *SessionEvent:
+		attrs := []any{
+			"server_name", data.ServerName,
+			"session_name", data.SessionName,
+			"session_type", data.SessionType,
+			"track", data.Track,
+			"track_config", data.TrackConfig,
+		}
+		if e.Type == EventNewSession {
+			logger.Info("new session", append(attrs, "laps", data.Laps, "time_minutes", data.TimeMinutes)...)
+		} else {
+			logger.Debug("session info", attrs...)
+		}
+	case *EndSessionEvent:
+		logger.Info("session ended", "session_type", data.SessionType, "report_file", data.ReportFile)
+	case *ConnectionEvent:
+		switch e.Type {
+		case EventNewConnection:
+			logger.Info("driver connected", append(driverAttrs(data.Driver), "car_skin", data.CarSkin)...)
+		case EventConnectionClosed:
+			logger.Info("driver disconnected", driverAttrs(data.Driver)...)
+		default:
+			logger.Info("client loaded", "car_id", data.CarID, "driver", data.Name)
+		}
+	case *CarInfoEvent:
+		logger.Debug("car info", append(driverAttrs(data.Driver), "car_skin", data.CarSkin, "connected", data.Connected)...)
+	case *LapEvent:
+		logger.Info("lap completed", append(driverAttrs(data.Driver),
+			"track", data.Track,
+			"lap", data.Lap,
+			"lap_ms", data.LapTime,
+			"cuts", data.Cuts,
+			"grip_level", round32(data.GripLevel, 3))...)
+	case *CollisionEvent:
+		attrs := []any{
+			"with", data.With.String(),
+			"car_id", data.CarID,
+			"driver", data.Name,
+			"guid", data.GUID,
+			"impact_speed_kmh", round32(data.ImpactSpeed, 1),
+		}
+		if data.Lap > 0 {
+			attrs = append(attrs, "lap", data.Lap)
+		}
+		if other := data.Other; other != nil {
+			attrs = append(attrs, "other_car_id", other.CarID, "other_driver", other.Name, "other_guid", other.GUID)
+		}
+		logger.Info("collision", attrs...)
+	case *ChatEvent:
+		logger.Info("chat message", "car_id", data.CarID, "driver", data.Name, "message", data.Message)
+	case *VersionEvent:
+		logger.Info("protocol version", "version", data.Version)
+	case *ErrorEvent:
+		logger.Warn("server error", "message", data.Message)
+	}
+}
+
+func driverAttrs(driver Driver) []any {
+	return []any{"car_id", driver.CarID, "driver", driver.Name, "guid", driver.GUID, "car_model", driver.CarModel}
+}
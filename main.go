@@ -1,80 +1,170 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
+	
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	host := os.Getenv("AC_SERVER_HOST")
-	if host == "" {
-		host = "127.0.0.1"
+	config, checkConfig, err := LoadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-	
-	udpPort := 9600
-	if portEnv := os.Getenv("AC_SERVER_UDP_PORT"); portEnv != "" {
-		fmt.Sscanf(portEnv, "%d", &udpPort)
+	if err != nil {
+		fatal("invalid configuration", "err", err)
 	}
-	
-	httpPort := 8081
-	if portEnv := os.Getenv("AC_SERVER_HTTP_PORT"); portEnv != "" {
-		fmt.Sscanf(portEnv, "%d", &httpPort)
+	if checkConfig {
+		fmt.Print(config)
+		return
 	}
+	adminToken := config.AdminToken
 	
-	metricsPort := os.Getenv("METRICS_PORT")
-	if metricsPort == "" {
-		metricsPort = "9090"
+	level, _ := parseLogLevel(config.LogLevel)
+	logLevel.Set(level)
+	if err := setupLogging(os.Stderr, config.LogFormat); err != nil {
+		fatal("invalid configuration", "err", err)
 	}
 	
-	fmt.Printf("Starting Assetto Corsa Prometheus Exporter\n")
-	fmt.Printf("Target Server: %s (UDP:%d, HTTP:%d)\n", host, udpPort, httpPort)
-	fmt.Printf("Metrics Port: %s\n\n", metricsPort)
-	
-	// Create monitor
-	monitor, err := NewACServerMonitor(host, udpPort, httpPort)
-	if err != nil {
-		log.Fatalf("Failed to create monitor: %v", err)
-	}
-	defer monitor.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 	
-	// Connect to UDP
-	if err := monitor.Connect(); err != nil {
-		log.Fatalf("Failed to connect: %v", err)
-	}
+	slog.Info("starting Assetto Corsa Prometheus exporter", "listen_address", config.ListenAddress)
 	
-	// Start UDP listener in background
-	go monitor.Listen()
+	// Protocol events are published on a bus, the event log is one of its
+	// subscribers
+	bus := NewEventBus()
+	eventLog := bus.Subscribe("log", logQueueSize, nil)
+	logged := make(chan struct{})
+	go func() {
+		defer close(logged)
+		LogEvents(eventLog)
+	}()
 	
-	// Initial stats fetch
-	time.Sleep(1 * time.Second)
-	monitor.GetCurrentStats()
+	// Start UDP listeners and pollers in background
+	monitors := NewMonitorSet(ctx, bus)
+	if err := monitors.Apply(config.Servers); err != nil {
+		fatal("failed to start monitors", "err", err)
+	}
 	
-	// Periodic stats refresh
+	var wg sync.WaitGroup
+	wg.Add(1)
 	go func() {
-		ticker := time.NewTicker(30 * time.Second)
+		defer wg.Done()
+		
+		// Periodic stats summary
+		ticker := time.NewTicker(config.StatsInterval)
 		defer ticker.Stop()
-		for range ticker.C {
-			monitor.GetCurrentStats()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, monitor := range monitors.Monitors() {
+					monitor.PrintStats()
+				}
+			}
 		}
 	}()
 	
 	// Setup Prometheus metrics
-	InitPrometheusMetrics(monitor)
+	exporter := NewExporter(monitors, bus)
+	exporter.SetDisabledGroups(config.DisabledMetrics)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		exporter,
+	)
+	
+	// Reload the configuration on SIGHUP
+	reloader := NewReloader(os.Args[1:], config, monitors, exporter)
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangup:
+				if err := reloader.Reload(); err != nil {
+					slog.Error("reload failed", "err", err)
+				}
+			}
+		}
+	}()
 	
 	// Setup HTTP server for metrics
-	http.Handle("/metrics", PrometheusHandler(monitor))
+	http.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+		ErrorHandling:     promhttp.ContinueOnError,
+		EnableOpenMetrics: true,
+	}))
+	http.HandleFunc("/probe", ProbeHandler)
 	http.HandleFunc("/health", HealthHandler)
+	http.HandleFunc("/events", EventsHandler(ctx, bus))
+	RegisterAPIHandlers(http.DefaultServeMux, monitors)
 	http.HandleFunc("/", IndexHandler)
+	if adminToken != "" {
+		RegisterAdminHandlers(http.DefaultServeMux, adminToken, monitors)
+		http.HandleFunc("/-/reload", RequireAdminToken(adminToken, ReloadHandler(reloader)))
+	}
+	
+	baseAddress := config.ListenAddress
+	if host, port, _ := net.SplitHostPort(baseAddress); host == "" {
+		baseAddress = net.JoinHostPort("localhost", port)
+	}
+	slog.Info("serving HTTP endpoints",
+		"metrics", "http://"+baseAddress+"/metrics",
+		"probe", "http://"+baseAddress+"/probe",
+		"health", "http://"+baseAddress+"/health",
+		"events", "http://"+baseAddress+"/events",
+		"api", "http://"+baseAddress+"/api/",
+		"admin", adminToken != "")
 	
-	fmt.Printf("✓ Metrics endpoint available at http://localhost:%s/metrics\n", metricsPort)
-	fmt.Printf("✓ Health check available at http://localhost:%s/health\n\n", metricsPort)
+	server := &http.Server{Addr: config.ListenAddress}
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	
+	select {
+	case err := <-serverErr:
+		fatal("failed to start HTTP server", "err", err)
+	case <-ctx.Done():
+	}
+	
+	// A second signal terminates immediately
+	stop()
+	slog.Info("shutting down", "drain_timeout", config.ShutdownTimeout.String())
 	
-	if err := http.ListenAndServe(":"+metricsPort, nil); err != nil {
-		log.Fatalf("Failed to start HTTP server: %v", err)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("HTTP server shutdown incomplete", "err", err)
 	}
+	monitors.Wait()
+	wg.Wait()
+	eventLog.Close()
+	<-logged
+	
+	slog.Info("stopped")
 }
 
 func HealthHandler(w http.ResponseWriter, r *http.Request) {
@@ -100,16 +190,20 @@ func IndexHandler(w http.ResponseWriter, r *http.Request) {
 <body>
     <div class="container">
         <h1>Assetto Corsa Prometheus Exporter</h1>
-        <p>This exporter collects metrics from an Assetto Corsa server and exposes them in Prometheus format.</p>
+        <p>This exporter collects metrics from one or more Assetto Corsa servers and exposes them in Prometheus format. Every metric carries a <code>server</code> label with the configured server name.</p>
         
         <div class="links">
             <a href="/metrics">Metrics</a>
+            <a href="/probe">Probe</a>
             <a href="/health">Health</a>
+            <a href="/events">Events</a>
+            <a href="/api/server">API</a>
         </div>
         
         <h2>Available Metrics</h2>
         <ul>
-            <li><code>ac_server_up</code> - Server availability (1 = up, 0 = down)</li>
+            <li><code>ac_server_up</code> - Server availability (1 = up, 0 = down): the last <code>/INFO</code> poll succeeded or a UDP packet arrived recently</li>
+            <li><code>ac_server_info</code> - Server name, track and version as labels, always 1</li>
             <li><code>ac_server_players</code> - Current number of connected players</li>
             <li><code>ac_server_max_players</code> - Maximum player capacity</li>
             <li><code>ac_server_session</code> - Current session type (0=Booking, 1=Practice, 2=Qualifying, 3=Race)</li>
@@ -117,11 +211,51 @@ func IndexHandler(w http.ResponseWriter, r *http.Request) {
             <li><code>ac_server_password_protected</code> - Whether server requires password (1 = yes, 0 = no)</li>
             <li><code>ac_server_pickup_mode</code> - Whether pickup mode is enabled (1 = yes, 0 = no)</li>
             <li><code>ac_server_time_left</code> - Time remaining in current session (seconds)</li>
+            <li><code>ac_server_info_age_seconds</code> - Time since the last successful <code>/INFO</code> poll (seconds)</li>
+            <li><code>ac_server_info_fetches_total</code> - Total <code>/INFO</code> polls</li>
+            <li><code>ac_server_info_fetch_errors_total</code> - Total failed <code>/INFO</code> polls</li>
+            <li><code>ac_server_info_fetch_duration_seconds</code> - Duration of the last <code>/INFO</code> poll (seconds)</li>
+            <li><code>ac_server_info_up</code> - Whether the last <code>/INFO</code> poll succeeded (1 = yes, 0 = no)</li>
+            <li><code>ac_server_udp_last_packet_age_seconds</code> - Time since the last UDP packet from the server (seconds)</li>
             <li><code>ac_server_lap_completed_total</code> - Total laps completed</li>
-            <li><code>ac_server_collisions_total</code> - Total collision events</li>
+            <li><code>ac_server_collisions_total</code> - Total collision events, by type (env, car)</li>
+            <li><code>ac_server_collision_impact_speed_kmh</code> - Collision impact speed histogram (km/h)</li>
+            <li><code>ac_server_driver_contacts_total</code> - Total car-to-car contacts per driver, by role (caused, received); drivers without a GUID have an empty <code>guid</code> and are told apart by <code>driver</code></li>
             <li><code>ac_server_connections_total</code> - Total player connections</li>
             <li><code>ac_server_disconnections_total</code> - Total player disconnections</li>
+            <li><code>ac_server_lap_time_seconds</code> - Lap time histogram, per driver, car and track</li>
+            <li><code>ac_server_best_lap_seconds</code> - Fastest lap without cuts, per driver, car and track</li>
+            <li><code>ac_server_last_lap_seconds</code> - Most recent lap, per driver, car and track</li>
+            <li><code>ac_server_lap_cuts_total</code> - Total track-limit cuts, per driver, car and track</li>
+            <li><code>ac_server_invalid_laps_total</code> - Total laps with at least one cut, per driver, car and track</li>
+            <li><code>ac_server_grip_level</code> - Track grip level (0-1)</li>
+            <li><code>ac_server_standings_position</code> - Current leaderboard position, per car</li>
+            <li><code>ac_server_standings_best_lap_seconds</code> - Best lap in the current session, per car</li>
+            <li><code>ac_server_standings_laps</code> - Laps completed in the current session, per car</li>
+            <li><code>ac_server_standings_finished</code> - Whether the car has finished the session, per car</li>
+            <li><code>ac_server_sessions_completed_total</code> - Total sessions completed, by session type</li>
+            <li><code>ac_server_session_result_position</code> - Finishing position in the last completed session, per driver</li>
+            <li><code>ac_server_session_result_total_time_seconds</code> - Total time in the last completed session, per driver</li>
+            <li><code>ac_server_session_result_best_lap_seconds</code> - Best lap in the last completed session, per driver</li>
+            <li><code>ac_server_session_result_laps</code> - Laps completed in the last completed session, per driver</li>
+            <li><code>ac_server_relay_packets_total</code> - Total packets relayed to and from downstream plugins, by direction</li>
+            <li><code>ac_server_udp_foreign_packets_total</code> - Total UDP packets dropped because they came neither from the server nor from a relay target</li>
+            <li><code>ac_server_car_info</code> - Car slot model, skin and driver as labels, always 1, per car</li>
+            <li><code>ac_server_car_connected</code> - Whether a driver occupies the car slot (1 = yes, 0 = no), per car</li>
+            <li><code>ac_server_players_by_model</code> - Connected players per car model</li>
+            <li><code>ac_server_car_info_requests_total</code> - Total car info requests sent to the server</li>
+            <li><code>ac_server_car_info_timeouts_total</code> - Total car info requests the server did not answer in time</li>
+            <li><code>ac_server_car_info_unanswered_slots</code> - Car slots whose last info request went unanswered</li>
+            <li><code>ac_server_car_speed_kmh</code> - Current car speed (km/h), per car</li>
+            <li><code>ac_server_car_rpm</code> - Current engine RPM, per car</li>
+            <li><code>ac_server_car_gear</code> - Current gear (-1 = reverse, 0 = neutral), per car</li>
+            <li><code>ac_server_car_spline_position</code> - Normalized position along the track (0-1), per car</li>
+            <li><code>ac_exporter_event_subscriptions</code> - Current event bus subscriptions, per subscriber</li>
+            <li><code>ac_exporter_event_queue_length</code> - Events waiting in the subscribers' queues, per subscriber</li>
+            <li><code>ac_exporter_events_delivered_total</code> - Total events queued for subscribers, per subscriber</li>
+            <li><code>ac_exporter_events_dropped_total</code> - Total events dropped because a subscriber's queue was full, per subscriber</li>
         </ul>
+        <p>The standard <code>go_*</code> and <code>process_*</code> runtime metrics are exported as well.</p>
     </div>
 </body>
 </html>`
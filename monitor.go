@@ -1,91 +1,333 @@
 package main
 
 import (
-	"bytes"
-	"encoding/binary"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+	
+	"github.com/prometheus/client_golang/prometheus"
+	
+	"acserver-exporter/acsp"
+)
+
+// maxRecentLaps is the number of laps kept in a monitor's lap history.
+const maxRecentLaps = 1000
+
+// Car telemetry is dropped once a car has missed this many realtime
+// updates, but never sooner than minTelemetryAge.
+const (
+	missedCarUpdates = 3
+	minTelemetryAge  = time.Second
 )
 
 type ACServerMonitor struct {
+	name               string
+	logger             *slog.Logger // records carry the server name
 	conn               *net.UDPConn
 	serverAddr         *net.UDPAddr
 	httpHost           string
 	httpPort           int
+	httpTimeout        time.Duration
 	cars               map[uint8]*CarInfo
+	telemetry          map[uint8]*CarTelemetry
 	mu                 sync.RWMutex
-	serverInfo         *ServerInfo
+	info               *InfoSnapshot // latest successful /INFO poll
+	infoInterval       time.Duration
+	infoUp             bool // last /INFO poll succeeded
+	lastPacket         time.Time // last UDP packet from the server
+	staleAfter         time.Duration
+	carInfo            *carInfoPoller
 	serverName         string
 	trackName          string
 	sessionType        string
+	realtimeInterval   uint16 // ms between car updates
+	resultsDir         string
+	lastResults        *SessionResults
+	endedSessions      chan endedSession // waiting for their results to be loaded
+	standings          []Standing
+	gripLevel          float32
+	relayTargets       []*net.UDPAddr
+	events             *EventBus
 	
 	// Metrics counters
 	totalLaps          int64
-	totalCollisions    int64
+	collisions         map[acsp.ClientEventType]int64
+	collisionExemplars map[acsp.ClientEventType]*prometheus.Exemplar // latest per type
+	impactSpeeds       *histogram // km/h
+	contacts           map[contactsKey]*DriverContacts
+	relayedToPlugins   int64
+	relayedToServer    int64
+	foreignPackets     int64 // neither from the server nor a relay target
 	totalConnections   int64
 	totalDisconnections int64
+	sessionsCompleted  map[string]int64 // by session type
+	infoFetches        int64
+	infoFetchErrors    int64
+	infoFetchDuration  time.Duration // of the last /INFO poll
+	lapStats           map[DriverKey]*DriverLapStats
+	recentLaps         []LapRecord // oldest first
 	metricsLock        sync.RWMutex
 }
 
-func NewACServerMonitor(host string, udpPort int, httpPort int) (*ACServerMonitor, error) {
-	serverAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", host, udpPort))
+// NewACServerMonitor creates a monitor for the server in cfg that publishes
+// the server's events on bus.
+func NewACServerMonitor(cfg ServerConfig, bus *EventBus) (*ACServerMonitor, error) {
+	serverAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", cfg.Host, cfg.UDPPort))
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve UDP address: %v", err)
 	}
+	
+	relayTargets, err := resolveRelayTargets(cfg.RelayTargets)
+	if err != nil {
+		return nil, err
+	}
 
-	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4zero, Port: 0})
+	// The server sends plugin traffic to its UDP_PLUGIN_ADDRESS, which must
+	// point at this address
+	listenAddr, err := net.ResolveUDPAddr("udp", cfg.PluginListen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve plugin listen address: %v", err)
+	}
+	conn, err := net.ListenUDP("udp", listenAddr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create UDP connection: %v", err)
+		return nil, fmt.Errorf("failed to listen for plugin traffic on %s: %v", cfg.PluginListen, err)
 	}
 
 	return &ACServerMonitor{
-		conn:       conn,
-		serverAddr: serverAddr,
-		httpHost:   host,
-		httpPort:   httpPort,
-		cars:       make(map[uint8]*CarInfo),
+		name:               cfg.Name,
+		logger:             slog.Default().With("server", cfg.Name),
+		conn:               conn,
+		serverAddr:         serverAddr,
+		httpHost:           cfg.Host,
+		httpPort:           cfg.HTTPPort,
+		httpTimeout:        cfg.HTTPTimeout,
+		cars:               make(map[uint8]*CarInfo),
+		telemetry:          make(map[uint8]*CarTelemetry),
+		realtimeInterval:   cfg.RealtimeInterval,
+		infoInterval:       cfg.InfoInterval,
+		staleAfter:         cfg.StaleAfter,
+		carInfo:            newCarInfoPoller(cfg.CarInfoInterval, cfg.CarInfoTimeout),
+		resultsDir:         cfg.ResultsDir,
+		endedSessions:      make(chan endedSession, endedSessionsQueueSize),
+		relayTargets:       relayTargets,
+		events:             bus,
+		sessionsCompleted:  make(map[string]int64),
+		lapStats:           make(map[DriverKey]*DriverLapStats),
+		collisions:         make(map[acsp.ClientEventType]int64),
+		collisionExemplars: make(map[acsp.ClientEventType]*prometheus.Exemplar),
+		impactSpeeds:       newHistogram(impactSpeedBuckets),
+		contacts:           make(map[contactsKey]*DriverContacts),
+
 	}, nil
 }
 
+// Name returns the name the server is configured under.
+func (m *ACServerMonitor) Name() string {
+	return m.name
+}
+
 func (m *ACServerMonitor) Connect() error {
-	handshake := []byte{ACSP_REALTIMEPOS_INTERVAL}
+	if err := m.handshake(); err != nil {
+		return err
+	}
+	m.logger.Info("connected to server", "address", m.serverAddr.String())
+	return nil
+}
+
+// handshake asks the server for car updates at the realtime interval and
+// for the current session. The server forgets the interval when it
+// restarts, so this is repeated whenever it announces its version.
+func (m *ACServerMonitor) handshake() error {
+	m.mu.RLock()
+	interval := m.realtimeInterval
+	m.mu.RUnlock()
+	
+	handshake := acsp.RealtimePosInterval(interval)
 	_, err := m.conn.WriteToUDP(handshake, m.serverAddr)
 	if err != nil {
 		return fmt.Errorf("handshake failed: %v", err)
 	}
 
-	sessionInfoReq := []byte{ACSP_GET_SESSION_INFO}
+	sessionInfoReq := acsp.GetSessionInfo(acsp.CurrentSession)
 	_, err = m.conn.WriteToUDP(sessionInfoReq, m.serverAddr)
 	if err != nil {
 		return fmt.Errorf("session info request failed: %v", err)
 	}
-
-	fmt.Println("✓ Connected to Assetto Corsa server via UDP")
 	return nil
 }
 
-func (m *ACServerMonitor) RequestCarInfo(carID uint8) error {
-	req := []byte{ACSP_GET_CAR_INFO, carID}
-	_, err := m.conn.WriteToUDP(req, m.serverAddr)
+// send writes a command to the server's plugin port.
+func (m *ACServerMonitor) send(cmd []byte) error {
+	_, err := m.conn.WriteToUDP(cmd, m.serverAddr)
 	return err
 }
 
-func (m *ACServerMonitor) GetCurrentStats() {
-	if err := FetchHTTPInfo(m); err != nil {
-		log.Printf("HTTP API error: %v", err)
+func (m *ACServerMonitor) RequestCarInfo(carID uint8) error {
+	return m.send(acsp.GetCarInfo(carID))
+}
+
+// RealtimeInterval returns the car update interval in milliseconds.
+func (m *ACServerMonitor) RealtimeInterval() uint16 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.realtimeInterval
+}
+
+// SetRealtimeInterval asks the server to send car updates every intervalMS
+// milliseconds. The interval is remembered and re-sent when the server
+// restarts.
+func (m *ACServerMonitor) SetRealtimeInterval(intervalMS uint16) error {
+	if err := m.send(acsp.RealtimePosInterval(intervalMS)); err != nil {
+		return fmt.Errorf("realtime interval request failed: %v", err)
 	}
 	
-	for i := uint8(0); i < 50; i++ {
-		m.RequestCarInfo(i)
-		time.Sleep(10 * time.Millisecond)
+	m.mu.Lock()
+	m.realtimeInterval = intervalMS
+	m.mu.Unlock()
+	return nil
+}
+
+// SendChat sends a private chat message to the driver in carID.
+func (m *ACServerMonitor) SendChat(carID uint8, message string) error {
+	return m.send(acsp.SendChat(carID, message))
+}
+
+// BroadcastChat sends a chat message to every driver.
+func (m *ACServerMonitor) BroadcastChat(message string) error {
+	return m.send(acsp.BroadcastChat(message))
+}
+
+// KickUser kicks the driver in carID.
+func (m *ACServerMonitor) KickUser(carID uint8) error {
+	return m.send(acsp.KickUser(carID))
+}
+
+// NextSession skips to the next session.
+func (m *ACServerMonitor) NextSession() error {
+	return m.send(acsp.NextSession())
+}
+
+// RestartSession restarts the current session.
+func (m *ACServerMonitor) RestartSession() error {
+	return m.send(acsp.RestartSession())
+}
+
+// SetSessionInfo changes the settings of a session.
+func (m *ACServerMonitor) SetSessionInfo(settings acsp.SessionSettings) error {
+	return m.send(acsp.SetSessionInfo(settings))
+}
+
+// AdminCommand runs a server admin command, e.g. "/ballast 0 50".
+func (m *ACServerMonitor) AdminCommand(command string) error {
+	return m.send(acsp.AdminCommand(command))
+}
+
+// Run serves the monitor until ctx is cancelled: it starts the UDP listener,
+// the pollers and the results loader, then closes the socket and waits for all of them to stop.
+func (m *ACServerMonitor) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, run := range []func(context.Context){m.Listen, m.PollInfo, m.PollCarInfo, m.LoadResults} {
+		wg.Add(1)
+		go func(run func(context.Context)) {
+			defer wg.Done()
+			run(ctx)
+		}(run)
 	}
 	
-	time.Sleep(1 * time.Second)
-	m.PrintStats()
+	<-ctx.Done()
+	m.Close()
+	wg.Wait()
+}
+
+// PollInfo refreshes the /INFO snapshot every infoInterval until ctx is
+// cancelled, so that scrapes are served from memory and never wait on the
+// AC server.
+func (m *ACServerMonitor) PollInfo(ctx context.Context) {
+	ticker := time.NewTicker(m.infoInterval)
+	defer ticker.Stop()
+	for {
+		if err := FetchHTTPInfo(ctx, m); err != nil && ctx.Err() == nil {
+			m.logger.Warn("HTTP API error", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Info returns the latest /INFO snapshot, or nil if the server has not
+// answered yet.
+func (m *ACServerMonitor) Info() *InfoSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.info
+}
+
+// Session returns the session as last reported over UDP.
+func (m *ACServerMonitor) Session() SessionState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return SessionState{ServerName: m.serverName, Track: m.trackName, Type: m.sessionType, GripLevel: m.gripLevel}
+}
+
+// Cars returns the known car slots, ordered by car ID.
+func (m *ACServerMonitor) Cars() []CarInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	
+	cars := make([]CarInfo, 0, len(m.cars))
+	for _, car := range m.cars {
+		cars = append(cars, *car)
+	}
+	sort.Slice(cars, func(i, j int) bool { return cars[i].CarID < cars[j].CarID })
+	return cars
+}
+
+// Standings returns the leaderboard of the last completed lap, or nil if no
+// lap was completed in the current session.
+func (m *ACServerMonitor) Standings() []Standing {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.standings)
+}
+
+// RecentLaps returns the lap history, oldest first.
+func (m *ACServerMonitor) RecentLaps() []LapRecord {
+	m.metricsLock.RLock()
+	defer m.metricsLock.RUnlock()
+	return slices.Clone(m.recentLaps)
+}
+
+// Up reports whether the server answered its last /INFO poll or sent a UDP
+// packet within the staleness threshold.
+func (m *ACServerMonitor) Up(liveness Liveness, now time.Time) bool {
+	return liveness.InfoUp || (!liveness.LastPacket.IsZero() && now.Sub(liveness.LastPacket) <= m.staleAfter)
+}
+
+// telemetryCurrent reports whether a car's telemetry is recent enough to be
+// reported. The caller must hold mu.
+func (m *ACServerMonitor) telemetryCurrent(t *CarTelemetry, now time.Time) bool {
+	if m.realtimeInterval == 0 {
+		return false
+	}
+	maxAge := max(missedCarUpdates*time.Duration(m.realtimeInterval)*time.Millisecond, minTelemetryAge)
+	return now.Sub(t.UpdatedAt) <= maxAge
+}
+
+// Liveness returns what the monitor last heard from its server.
+func (m *ACServerMonitor) Liveness() Liveness {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return Liveness{Info: m.info, InfoUp: m.infoUp, LastPacket: m.lastPacket}
 }
 
 func (m *ACServerMonitor) PrintStats() {
@@ -99,281 +341,462 @@ func (m *ACServerMonitor) PrintStats() {
 		}
 	}
 	
-	if m.serverInfo != nil {
-		sessionNames := map[int]string{0: "Booking", 1: "Practice", 2: "Qualifying", 3: "Race"}
-		sessionName := sessionNames[m.serverInfo.Session]
-		
-		fmt.Printf("Server: %s | Track: %s | Mode: %s | Players: %d/%d\n",
-			m.serverInfo.Name, m.serverInfo.Track, sessionName,
-			connectedCars, m.serverInfo.MaxClients)
+	if m.info != nil {
+		info := m.info.Info
+		m.logger.Info("server stats",
+			"server_name", info.Name,
+			"track", info.Track,
+			"session_type", acsp.SessionType(info.Session).String(),
+			"players", connectedCars,
+			"max_players", info.MaxClients)
 	}
 }
 
-func (m *ACServerMonitor) Listen() {
+// Listen handles the packets received on the monitor's socket until ctx is
+// cancelled and the socket is closed.
+func (m *ACServerMonitor) Listen(ctx context.Context) {
 	buffer := make([]byte, 2048)
 	for {
-		n, _, err := m.conn.ReadFromUDP(buffer)
+		n, addr, err := m.conn.ReadFromUDP(buffer)
 		if err != nil {
-			log.Printf("Error reading UDP: %v", err)
+			if errors.Is(err, net.ErrClosed) || ctx.Err() != nil {
+				return
+			}
+			m.logger.Error("UDP read failed", "err", err)
 			continue
 		}
-		if n > 0 {
-			m.handleMessage(buffer[:n])
+		if n == 0 {
+			continue
 		}
+		if m.isRelayTarget(addr) {
+			m.relayToServer(buffer[:n], addr)
+			continue
+		}
+		// Anyone who can reach the socket could otherwise inject events
+		if !m.isServer(addr) {
+			m.metricsLock.Lock()
+			m.foreignPackets++
+			m.metricsLock.Unlock()
+			m.logger.Debug("UDP packet from unknown source dropped", "from", addr.String())
+			continue
+		}
+		
+		m.mu.Lock()
+		m.lastPacket = time.Now()
+		m.mu.Unlock()
+		
+		m.relayToPlugins(buffer[:n])
+		m.handleMessage(buffer[:n])
 	}
 }
 
 func (m *ACServerMonitor) handleMessage(data []byte) {
-	if len(data) == 0 {
+	msg, err := acsp.Decode(data)
+	if err != nil {
+		var unknown acsp.UnknownTypeError
+		if !errors.As(err, &unknown) {
+			m.logger.Warn("UDP message decoding failed", "err", err)
+		}
 		return
 	}
-
-	msgType := data[0]
 	
-	switch msgType {
-	case ACSP_NEW_SESSION:
-		m.handleNewSession(data[1:])
-	case ACSP_NEW_CONNECTION:
-		m.handleNewConnection(data[1:])
-	case ACSP_CONNECTION_CLOSED:
-		m.handleConnectionClosed(data[1:])
-	case ACSP_LAP_COMPLETED:
-		m.handleLapCompleted(data[1:])
-	case ACSP_CAR_INFO:
-		m.handleCarInfo(data[1:])
-	case ACSP_SESSION_INFO:
-		m.handleSessionInfo(data[1:])
-	case ACSP_CLIENT_EVENT:
-		m.handleClientEvent(data[1:])
-	case ACSP_CHAT:
-		m.handleChat(data[1:])
+	switch msg := msg.(type) {
+	case *acsp.NewSession:
+		m.handleNewSession(msg)
+	case *acsp.NewConnection:
+		m.handleNewConnection(msg)
+	case *acsp.ConnectionClosed:
+		m.handleConnectionClosed(msg)
+	case *acsp.LapCompleted:
+		m.handleLapCompleted(msg)
+	case *acsp.CarUpdate:
+		m.handleCarUpdate(msg)
+	case *acsp.CarInfo:
+		m.handleCarInfo(msg)
+	case *acsp.SessionInfo:
+		m.handleSessionInfo(msg)
+	case *acsp.EndSession:
+		m.handleEndSession(msg)
+	case *acsp.ClientEvent:
+		m.handleClientEvent(msg)
+	case *acsp.Chat:
+		m.publish(EventChat, &ChatEvent{Driver: m.driver(msg.CarID), Message: msg.Message})
+	case *acsp.ClientLoaded:
+		m.publish(EventClientLoaded, &ConnectionEvent{Driver: m.driver(msg.CarID)})
+	case *acsp.Version:
+		m.handleVersion(msg)
+	case *acsp.Error:
+		m.publish(EventError, &ErrorEvent{Message: msg.Message})
 	}
 }
 
-func (m *ACServerMonitor) handleNewSession(data []byte) {
-	if len(data) < 4 {
-		return
+// publish sends an event of the monitor's server to the bus.
+func (m *ACServerMonitor) publish(eventType EventType, data any) {
+	m.publishAt(time.Now(), eventType, data)
+}
+
+// publishAt sends an event that happened at the given time to the bus.
+func (m *ACServerMonitor) publishAt(at time.Time, eventType EventType, data any) {
+	m.events.Publish(Event{Server: m.name, Type: eventType, Time: at, Data: data})
+}
+
+// handleVersion handles the version the server announces when it starts,
+// which means it has forgotten the realtime interval.
+func (m *ACServerMonitor) handleVersion(msg *acsp.Version) {
+	m.logger.Info("server started, repeating handshake", "protocol_version", msg.ProtocolVersion)
+	if err := m.handshake(); err != nil {
+		m.logger.Warn("handshake after server start failed", "err", err)
 	}
-	
-	reader := bytes.NewReader(data)
-	var version, sessionIndex, currentSessionIndex, sessionCount uint8
-	
-	binary.Read(reader, binary.LittleEndian, &version)
-	binary.Read(reader, binary.LittleEndian, &sessionIndex)
-	binary.Read(reader, binary.LittleEndian, &currentSessionIndex)
-	binary.Read(reader, binary.LittleEndian, &sessionCount)
-	
-	serverName := readString(reader)
-	track := readString(reader)
-	trackConfig := readString(reader)
+	m.publish(EventVersion, &VersionEvent{Version: msg.ProtocolVersion})
+}
+
+func (m *ACServerMonitor) handleNewSession(msg *acsp.NewSession) {
+	m.updateSession(&msg.SessionInfo)
 	
 	m.mu.Lock()
-	m.serverName = serverName
-	m.trackName = fmt.Sprintf("%s (%s)", track, trackConfig)
+	m.standings = nil
+	m.telemetry = make(map[uint8]*CarTelemetry)
 	m.mu.Unlock()
 	
-	fmt.Printf("🏁 NEW SESSION: %s on %s\n", serverName, track)
+	m.sweepCarInfo()
+	
+	m.publish(EventNewSession, sessionEvent(&msg.SessionInfo))
 }
 
-func (m *ACServerMonitor) handleNewConnection(data []byte) {
-	if len(data) < 1 {
-		return
-	}
-	
-	reader := bytes.NewReader(data)
-	driverName := readString(reader)
-	driverGUID := readString(reader)
-	
-	var carID, carModel, carSkin uint8
-	binary.Read(reader, binary.LittleEndian, &carID)
-	binary.Read(reader, binary.LittleEndian, &carModel)
-	binary.Read(reader, binary.LittleEndian, &carSkin)
-	
+func (m *ACServerMonitor) handleNewConnection(msg *acsp.NewConnection) {
 	m.mu.Lock()
-	if m.cars[carID] == nil {
-		m.cars[carID] = &CarInfo{}
+	car := m.cars[msg.CarID]
+	if car == nil {
+		car = &CarInfo{}
+		m.cars[msg.CarID] = car
 	}
-	m.cars[carID].CarID = carID
-	m.cars[carID].IsConnected = true
-	m.cars[carID].DriverName = driverName
-	m.cars[carID].DriverGUID = driverGUID
+	car.CarID = msg.CarID
+	car.IsConnected = true
+	car.CarModel = msg.CarModel
+	car.CarSkin = msg.CarSkin
+	car.DriverName = msg.DriverName
+	car.DriverGUID = msg.DriverGUID
 	m.mu.Unlock()
 	
+	m.carInfo.enqueue(msg.CarID)
+	
 	m.metricsLock.Lock()
 	m.totalConnections++
 	m.metricsLock.Unlock()
 	
-	fmt.Printf("CONNECTED: %s (Car #%d)\n", driverName, carID)
+	m.publish(EventNewConnection, &ConnectionEvent{
+		Driver:  Driver{CarID: msg.CarID, Name: msg.DriverName, GUID: msg.DriverGUID, CarModel: msg.CarModel},
+		CarSkin: msg.CarSkin,
+	})
 }
 
-func (m *ACServerMonitor) handleConnectionClosed(data []byte) {
-	if len(data) < 1 {
-		return
-	}
-	
-	reader := bytes.NewReader(data)
-	driverName := readString(reader)
-	var carID uint8
-	binary.Read(reader, binary.LittleEndian, &carID)
-	
+func (m *ACServerMonitor) handleConnectionClosed(msg *acsp.ConnectionClosed) {
 	m.mu.Lock()
-	if m.cars[carID] != nil {
-		m.cars[carID].IsConnected = false
+	if m.cars[msg.CarID] != nil {
+		m.cars[msg.CarID].IsConnected = false
 	}
+	delete(m.telemetry, msg.CarID)
 	m.mu.Unlock()
 	
 	m.metricsLock.Lock()
 	m.totalDisconnections++
 	m.metricsLock.Unlock()
 	
-	fmt.Printf("DISCONNECTED: %s (Car #%d)\n", driverName, carID)
+	m.publish(EventConnectionClosed, &ConnectionEvent{
+		Driver: Driver{CarID: msg.CarID, Name: msg.DriverName, GUID: msg.DriverGUID, CarModel: msg.CarModel},
+	})
 }
 
-func (m *ACServerMonitor) handleLapCompleted(data []byte) {
-	if len(data) < 9 {
-		return
+func (m *ACServerMonitor) handleLapCompleted(msg *acsp.LapCompleted) {
+	standings := make([]Standing, len(msg.Leaderboard))
+	lap := 0
+	for i, entry := range msg.Leaderboard {
+		standings[i] = Standing{
+			Position:  i + 1,
+			CarID:     entry.CarID,
+			BestLap:   entry.Time,
+			Laps:      entry.Laps,
+			Completed: entry.Completed,
+		}
+		if entry.CarID == msg.CarID {
+			lap = int(entry.Laps)
+		}
 	}
 	
-	reader := bytes.NewReader(data)
-	var carID uint8
-	var lapTime uint32
-	var cuts uint8
+	m.mu.Lock()
+	m.standings = standings
+	m.gripLevel = msg.GripLevel
+	m.mu.Unlock()
 	
-	binary.Read(reader, binary.LittleEndian, &carID)
-	binary.Read(reader, binary.LittleEndian, &lapTime)
-	binary.Read(reader, binary.LittleEndian, &cuts)
+	event := &LapEvent{
+		Driver:    m.driver(msg.CarID),
+		Track:     m.track(),
+		Lap:       lap,
+		LapTime:   msg.LapTime,
+		Cuts:      msg.Cuts,
+		GripLevel: msg.GripLevel,
+	}
+	now := time.Now()
 	
 	m.metricsLock.Lock()
-	m.totalLaps++
+	m.recordLap(now, event)
 	m.metricsLock.Unlock()
 	
-	lapTimeSeconds := float64(lapTime) / 1000.0
-	minutes := int(lapTimeSeconds / 60)
-	seconds := lapTimeSeconds - float64(minutes*60)
+	m.publishAt(now, EventLapCompleted, event)
+}
+
+func (m *ACServerMonitor) handleCarUpdate(msg *acsp.CarUpdate) {
+	m.mu.Lock()
+	if m.cars[msg.CarID] == nil {
+		m.carInfo.enqueue(msg.CarID)
+	}
+	m.telemetry[msg.CarID] = &CarTelemetry{
+		Position:            msg.Pos,
+		Velocity:            msg.Velocity,
+		Gear:                msg.Gear,
+		EngineRPM:           msg.EngineRPM,
+		NormalizedSplinePos: msg.NormalizedSplinePos,
+		UpdatedAt:           time.Now(),
+	}
+	m.mu.Unlock()
+}
+
+func (m *ACServerMonitor) handleCarInfo(msg *acsp.CarInfo) {
+	m.carInfo.answered(msg.CarID)
 	
-	driverName := fmt.Sprintf("Car #%d", carID)
-	m.mu.RLock()
-	if m.cars[carID] != nil {
-		driverName = m.cars[carID].DriverName
+	m.mu.Lock()
+	if !msg.IsConnected {
+		delete(m.telemetry, msg.CarID)
 	}
-	m.mu.RUnlock()
+	m.cars[msg.CarID] = &CarInfo{
+		CarID:       msg.CarID,
+		IsConnected: msg.IsConnected,
+		CarModel:    msg.CarModel,
+		CarSkin:     msg.CarSkin,
+		DriverName:  msg.DriverName,
+		DriverGUID:  msg.DriverGUID,
+	}
+	m.mu.Unlock()
 	
-	fmt.Printf("LAP: %s - %02d:%06.3f\n", driverName, minutes, seconds)
+	m.publish(EventCarInfo, &CarInfoEvent{
+		Driver:    Driver{CarID: msg.CarID, Name: msg.DriverName, GUID: msg.DriverGUID, CarModel: msg.CarModel},
+		CarSkin:   msg.CarSkin,
+		Connected: msg.IsConnected,
+	})
 }
 
-func (m *ACServerMonitor) handleCarInfo(data []byte) {
-	if len(data) < 1 {
-		return
+func (m *ACServerMonitor) handleSessionInfo(msg *acsp.SessionInfo) {
+	m.updateSession(msg)
+	m.publish(EventSessionInfo, sessionEvent(msg))
+}
+
+// updateSession records the server name, track and session type of msg.
+func (m *ACServerMonitor) updateSession(msg *acsp.SessionInfo) {
+	trackName := msg.Track
+	if msg.TrackConfig != "" {
+		trackName = fmt.Sprintf("%s (%s)", msg.Track, msg.TrackConfig)
 	}
 	
-	reader := bytes.NewReader(data)
-	var carID, isConnected uint8
-	
-	binary.Read(reader, binary.LittleEndian, &carID)
-	binary.Read(reader, binary.LittleEndian, &isConnected)
-	
-	carModel := readString(reader)
-	carSkin := readString(reader)
-	driverName := readString(reader)
-	driverGUID := readString(reader)
-	
 	m.mu.Lock()
-	m.cars[carID] = &CarInfo{
-		CarID:       carID,
-		IsConnected: isConnected == 1,
-		CarModel:    carModel,
-		CarSkin:     carSkin,
-		DriverName:  driverName,
-		DriverGUID:  driverGUID,
-	}
+	m.serverName = msg.ServerName
+	m.trackName = trackName
+	m.sessionType = msg.Type.String()
 	m.mu.Unlock()
 }
 
-func (m *ACServerMonitor) handleSessionInfo(data []byte) {
-	if len(data) < 13 {
-		return
+func sessionEvent(msg *acsp.SessionInfo) *SessionEvent {
+	return &SessionEvent{
+		ServerName:  msg.ServerName,
+		SessionName: msg.Name,
+		SessionType: msg.Type.String(),
+		Track:       msg.Track,
+		TrackConfig: msg.TrackConfig,
+		Laps:        msg.Laps,
+		TimeMinutes: msg.Time,
 	}
+}
+
+// handleEndSession records the end of a session. When the session's results
+// are to be exported, they are loaded by LoadResults so that reading the file
+// does not hold up the listener.
+func (m *ACServerMonitor) handleEndSession(msg *acsp.EndSession) {
+	m.mu.RLock()
+	ended := endedSession{sessionType: m.sessionType, reportFile: msg.ReportFile}
+	m.mu.RUnlock()
 	
-	reader := bytes.NewReader(data)
-	var version, sessionIndex, currentSessionIndex, sessionCount uint8
-	
-	binary.Read(reader, binary.LittleEndian, &version)
-	binary.Read(reader, binary.LittleEndian, &sessionIndex)
-	binary.Read(reader, binary.LittleEndian, &currentSessionIndex)
-	binary.Read(reader, binary.LittleEndian, &sessionCount)
-	
-	serverName := readString(reader)
-	var sessionType uint8
-	var sessionTime, laps, waitTime uint16
-	
-	binary.Read(reader, binary.LittleEndian, &sessionType)
-	binary.Read(reader, binary.LittleEndian, &sessionTime)
-	binary.Read(reader, binary.LittleEndian, &laps)
-	binary.Read(reader, binary.LittleEndian, &waitTime)
-	
-	_ = readString(reader)
-	_ = readString(reader)
-	_ = readString(reader)
-	_ = readString(reader)
-	
-	sessionTypes := []string{"Practice", "Qualifying", "Race"}
-	sessionTypeName := "Unknown"
-	if int(sessionType) < len(sessionTypes) {
-		sessionTypeName = sessionTypes[sessionType]
+	if m.resultsDir != "" && msg.ReportFile != "" {
+		select {
+		case m.endedSessions <- ended:
+			return
+		default:
+			m.logger.Warn("session results skipped, too many sessions waiting", "report_file", msg.ReportFile)
+		}
 	}
-	
-	m.mu.Lock()
-	m.serverName = serverName
-	m.sessionType = sessionTypeName
-	m.mu.Unlock()
+	m.endSession(ended, nil)
 }
 
-func (m *ACServerMonitor) handleClientEvent(data []byte) {
-	if len(data) < 2 {
-		return
+// endSession counts a completed session, keeps its results and publishes
+// the end_session event. A session without loadable results clears those
+// of the previous session, which are no longer the last ones.
+func (m *ACServerMonitor) endSession(ended endedSession, results *SessionResults) {
+	sessionType := ended.sessionType
+	if sessionType == "" && results != nil {
+		sessionType = results.SessionType()
+	}
+	if sessionType == "" {
+		sessionType = "Unknown"
 	}
+	m.mu.Lock()
+	m.lastResults = results
+	m.mu.Unlock()
 	
-	reader := bytes.NewReader(data)
-	var carID, eventType uint8
+	m.metricsLock.Lock()
+	m.sessionsCompleted[strings.ToLower(sessionType)]++
+	m.metricsLock.Unlock()
 	
-	binary.Read(reader, binary.LittleEndian, &carID)
-	binary.Read(reader, binary.LittleEndian, &eventType)
+	m.publish(EventEndSession, &EndSessionEvent{SessionType: sessionType, ReportFile: ended.reportFile})
+}
+
+func (m *ACServerMonitor) handleClientEvent(msg *acsp.ClientEvent) {
+	event := &CollisionEvent{
+		Driver:      m.driver(msg.CarID),
+		With:        msg.Type,
+		ImpactSpeed: msg.ImpactSpeed,
+		Lap:         m.currentLap(msg.CarID),
+	}
+	if msg.Type == acsp.CollisionWithCar {
+		other := m.driver(msg.OtherCarID)
+		event.Other = &other
+	}
+	now := time.Now()
 	
 	m.metricsLock.Lock()
-	m.totalCollisions++
+	m.recordCollision(now, event)
 	m.metricsLock.Unlock()
 	
-	events := map[uint8]string{0: "Collision with ENV", 1: "Collision with CAR"}
-	eventName := events[eventType]
+	m.publishAt(now, EventCollision, event)
+}
+
+// recordLap adds a lap to the driver's statistics and the lap history. The
+// caller must hold metricsLock.
+func (m *ACServerMonitor) recordLap(at time.Time, lap *LapEvent) {
+	exemplar := exemplarLabels(lap.GUID, lap.Lap)
 	
-	driverName := fmt.Sprintf("Car #%d", carID)
-	m.mu.RLock()
-	if m.cars[carID] != nil {
-		driverName = m.cars[carID].DriverName
+	m.totalLaps++
+	key := DriverKey{GUID: lap.GUID, CarModel: lap.CarModel, Track: lap.Track}
+	if lap.GUID == "" {
+		key.Name = lap.Name
+	}
+	stats := m.lapStats[key]
+	if stats == nil {
+		stats = &DriverLapStats{LapTimes: newHistogram(lapTimeBuckets)}
+		m.lapStats[key] = stats
+	}
+	stats.DriverName = lap.Name
+	stats.LapTimes.ObserveWithExemplar(float64(lap.LapTime)/1000.0, exemplar)
+	stats.LastLap = lap.LapTime
+	// Laps with cuts are invalid and do not count as personal bests.
+	if lap.Cuts > 0 {
+		stats.Cuts += uint64(lap.Cuts)
+		stats.InvalidLaps++
+	} else if stats.BestLap == 0 || lap.LapTime < stats.BestLap {
+		stats.BestLap = lap.LapTime
 	}
-	m.mu.RUnlock()
 	
-	fmt.Printf("⚡ EVENT: %s - %s\n", driverName, eventName)
+	if len(m.recentLaps) == maxRecentLaps {
+		copy(m.recentLaps, m.recentLaps[1:])
+		m.recentLaps = m.recentLaps[:maxRecentLaps-1]
+	}
+	m.recentLaps = append(m.recentLaps, LapRecord{Time: at, LapEvent: *lap})
 }
 
-func (m *ACServerMonitor) handleChat(data []byte) {
-	if len(data) < 1 {
-		return
+// recordCollision counts a collision and, for car contacts, the contacts of
+// both drivers. Drivers only known by their car slot get no contact
+// counters, which would be labelled with a made-up name. The caller must
+// hold metricsLock.
+func (m *ACServerMonitor) recordCollision(at time.Time, collision *CollisionEvent) {
+	exemplar := exemplarLabels(collision.GUID, collision.Lap)
+	
+	m.collisions[collision.With]++
+	m.collisionExemplars[collision.With] = &prometheus.Exemplar{Value: 1, Labels: exemplar, Timestamp: at}
+	m.impactSpeeds.ObserveWithExemplar(float64(collision.ImpactSpeed), exemplar)
+	
+	if other := collision.Other; other != nil {
+		if collision.Known() {
+			m.driverContacts(collision.GUID, collision.Name).Caused++
+		}
+		if other.Known() {
+			m.driverContacts(other.GUID, other.Name).Received++
+		}
 	}
+}
+
+// contactsKey identifies a driver's contact counters: by GUID, or by name for
+// drivers without one.
+type contactsKey struct {
+	GUID string
+	Name string // only without a GUID
+}
+
+// driverContacts returns the contact counters of a driver, creating them if
+// needed. Drivers without a GUID are tracked by name. The caller must hold
+// metricsLock.
+func (m *ACServerMonitor) driverContacts(guid string, driverName string) *DriverContacts {
+	key := contactsKey{GUID: guid}
+	if guid == "" {
+		key.Name = driverName
+	}
+	contacts := m.contacts[key]
+	if contacts == nil {
+		contacts = &DriverContacts{GUID: guid}
+		m.contacts[key] = contacts
+	}
+	contacts.DriverName = driverName
+	return contacts
+}
+
+// driver returns the driver in carID, with a placeholder name if the slot is
+// unknown or has no driver.
+func (m *ACServerMonitor) driver(carID uint8) Driver {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	
-	reader := bytes.NewReader(data)
-	var carID uint8
-	binary.Read(reader, binary.LittleEndian, &carID)
-	message := readString(reader)
-	
-	driverName := fmt.Sprintf("Car #%d", carID)
+	driver := Driver{CarID: carID, Name: fmt.Sprintf("Car #%d", carID), placeholder: true}
+	if car := m.cars[carID]; car != nil {
+		driver.GUID = car.DriverGUID
+		driver.CarModel = car.CarModel
+		if car.DriverName != "" {
+			driver.Name = car.DriverName
+		}
+		driver.placeholder = car.DriverName == "" && car.DriverGUID == ""
+	}
+	return driver
+}
+
+// track returns the track of the current session, falling back to the one
+// reported by /INFO.
+func (m *ACServerMonitor) track() string {
 	m.mu.RLock()
-	if m.cars[carID] != nil {
-		driverName = m.cars[carID].DriverName
+	defer m.mu.RUnlock()
+	
+	if m.trackName == "" && m.info != nil {
+		return m.info.Info.Track
 	}
-	m.mu.RUnlock()
+	return m.trackName
+}
+
+// currentLap returns the lap carID is on according to the latest standings,
+// or 0 if it has not completed a lap yet.
+func (m *ACServerMonitor) currentLap(carID uint8) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	
-	fmt.Printf("CHAT [%s]: %s\n", driverName, message)
+	for _, st := range m.standings {
+		if st.CarID == carID {
+			return int(st.Laps) + 1
+		}
+	}
+	return 0
 }
 
 func (m *ACServerMonitor) GetConnectedCount() int {
@@ -394,19 +817,3 @@ func (m *ACServerMonitor) Close() {
 		m.conn.Close()
 	}
 }
-
-func readString(reader *bytes.Reader) string {
-	var length uint8
-	err := binary.Read(reader, binary.LittleEndian, &length)
-	if err != nil || length == 0 {
-		return ""
-	}
-	
-	strBytes := make([]byte, length)
-	_, err = reader.Read(strBytes)
-	if err != nil {
-		return ""
-	}
-	
-	return strings.TrimRight(string(strBytes), "\x00")
-}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"acserver-exporter/acsp"
+)
+
+func TestRecordLapWithoutGUID(t *testing.T) {
+	m := &ACServerMonitor{lapStats: make(map[DriverKey]*DriverLapStats)}
+	laps := []LapEvent{
+		{Driver: Driver{CarID: 1, Name: "Alice", CarModel: "ks_mazda_mx5_cup"}, Track: "monza", LapTime: 90000},
+		{Driver: Driver{CarID: 2, Name: "Bob", CarModel: "ks_mazda_mx5_cup"}, Track: "monza", LapTime: 95000},
+		{Driver: Driver{CarID: 1, Name: "Alice", CarModel: "ks_mazda_mx5_cup"}, Track: "monza", LapTime: 89000},
+	}
+	for i := range laps {
+		m.recordLap(time.Now(), &laps[i])
+	}
+
+	if len(m.lapStats) != 2 {
+		t.Fatalf("got %d lap series, want 2", len(m.lapStats))
+	}
+	for key, stats := range m.lapStats {
+		if key.Name != stats.DriverName {
+			t.Errorf("series keyed by %q holds laps of %q", key.Name, stats.DriverName)
+		}
+		want := map[string]uint32{"Alice": 89000, "Bob": 95000}[stats.DriverName]
+		if stats.BestLap != want {
+			t.Errorf("%s: best lap = %d, want %d", stats.DriverName, stats.BestLap, want)
+		}
+	}
+}
+
+func TestNewSessionClearsTelemetry(t *testing.T) {
+	m := testMonitor(t)
+	m.telemetry[3] = &CarTelemetry{EngineRPM: 7000, UpdatedAt: time.Now()}
+
+	m.handleNewSession(&acsp.NewSession{})
+	if len(m.telemetry) != 0 {
+		t.Errorf("telemetry of %d cars kept across sessions", len(m.telemetry))
+	}
+}
+
+func TestVersionRepeatsHandshake(t *testing.T) {
+	server := listenLoopback(t)
+
+	cfg := defaultServerConfig
+	cfg.Host = "127.0.0.1"
+	cfg.UDPPort = server.LocalAddr().(*net.UDPAddr).Port
+	cfg.PluginListen = "127.0.0.1:0"
+	m, err := NewACServerMonitor(cfg, NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Listen(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		m.Close()
+		<-done
+	})
+	if err := m.SetRealtimeInterval(250); err != nil {
+		t.Fatal(err)
+	}
+	receive(t, server)
+
+	// A restarted server announces its version and has to be asked again
+	if _, err := server.WriteToUDP([]byte{acsp.MsgVersion, 4}, m.conn.LocalAddr().(*net.UDPAddr)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range [][]byte{acsp.RealtimePosInterval(250), acsp.GetSessionInfo(acsp.CurrentSession)} {
+		if got, _ := receive(t, server); !bytes.Equal(got, want) {
+			t.Errorf("server received %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCollisionWithUnknownDriver(t *testing.T) {
+	m := testMonitor(t)
+	m.cars[0] = &CarInfo{CarID: 0, DriverName: "Alice", IsConnected: true}
+	m.cars[1] = &CarInfo{CarID: 1} // empty slot
+
+	m.handleClientEvent(&acsp.ClientEvent{Type: acsp.CollisionWithCar, CarID: 0, OtherCarID: 1})
+	m.handleClientEvent(&acsp.ClientEvent{Type: acsp.CollisionWithCar, CarID: 2, OtherCarID: 0})
+
+	if len(m.contacts) != 1 {
+		t.Fatalf("got contact counters for %d drivers, want only Alice", len(m.contacts))
+	}
+	alice := m.contacts[contactsKey{Name: "Alice"}]
+	if alice == nil || alice.Caused != 1 || alice.Received != 1 {
+		t.Errorf("Alice's contacts = %+v, want 1 caused and 1 received", alice)
+	}
+	if m.collisions[acsp.CollisionWithCar] != 2 {
+		t.Errorf("car collisions = %d, want 2", m.collisions[acsp.CollisionWithCar])
+	}
+}
+
+func TestEndSessionWithoutResults(t *testing.T) {
+	m := testMonitor(t)
+	m.lastResults = &SessionResults{Type: "QUALIFY"}
+
+	m.endSession(endedSession{sessionType: "Race", reportFile: "missing.json"}, nil)
+	if m.lastResults != nil {
+		t.Error("results of the previous session kept after a session without results")
+	}
+	if m.sessionsCompleted["race"] != 1 {
+		t.Errorf("completed races = %d, want 1", m.sessionsCompleted["race"])
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// MonitorSet runs one ACServerMonitor per configured server and replaces
+// them when the configuration changes.
+type MonitorSet struct {
+	ctx    context.Context // parent of every monitor's lifetime
+	events *EventBus
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	runs   []*monitorRun
+}
+
+// monitorRun is a running monitor along with the configuration it was
+// created from.
+type monitorRun struct {
+	monitor *ACServerMonitor
+	config  ServerConfig
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewMonitorSet returns an empty set whose monitors run until ctx is
+// cancelled and publish their events on bus.
+func NewMonitorSet(ctx context.Context, bus *EventBus) *MonitorSet {
+	return &MonitorSet{ctx: ctx, events: bus}
+}
+
+// Monitors returns the running monitors in configuration order.
+func (s *MonitorSet) Monitors() []*ACServerMonitor {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	monitors := make([]*ACServerMonitor, len(s.runs))
+	for i, run := range s.runs {
+		monitors[i] = run.monitor
+	}
+	return monitors
+}
+
+// Apply makes the set monitor exactly the given servers. Monitors whose
+// configuration is unchanged keep running along with their counters; new
+// and changed servers get a fresh monitor and removed ones are stopped. If
+// a monitor cannot be started, the set is left as it was, except that
+// monitors which had to give up their plugin port are restarted with their
+// previous configuration and fresh counters; those that cannot be restarted
+// are removed from the set and reported in the error.
+func (s *MonitorSet) Apply(configs []ServerConfig) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	current := make(map[string]*monitorRun, len(s.runs))
+	for _, run := range s.runs {
+		current[run.config.Name] = run
+	}
+	s.mu.Unlock()
+
+	kept := make(map[*monitorRun]bool, len(configs))
+	ports := make(map[int]bool)
+	for _, cfg := range configs {
+		if run := current[cfg.Name]; run != nil && reflect.DeepEqual(run.config, cfg) {
+			kept[run] = true
+		} else {
+			ports[addressPort(cfg.PluginListen)] = true
+		}
+	}
+
+	// A plugin port can only be bound once, so a monitor whose port is
+	// wanted by a new one is stopped before the new one is created
+	var released []*monitorRun
+	for _, run := range current {
+		if !kept[run] && ports[addressPort(run.config.PluginListen)] {
+			s.stop(run)
+			released = append(released, run)
+		}
+	}
+
+	var started []*monitorRun
+	next := make([]*monitorRun, 0, len(configs))
+	for _, cfg := range configs {
+		if run := current[cfg.Name]; kept[run] {
+			next = append(next, run)
+			continue
+		}
+
+		run, err := newMonitorRun(cfg, s.events)
+		if err != nil {
+			for _, run := range started {
+				run.monitor.Close()
+			}
+			return errors.Join(err, s.restore(released))
+		}
+		started = append(started, run)
+		next = append(next, run)
+	}
+
+	s.mu.Lock()
+	s.runs = next
+	s.mu.Unlock()
+
+	for _, run := range current {
+		if !kept[run] && !slices.Contains(released, run) {
+			s.stop(run)
+		}
+	}
+	for _, run := range started {
+		s.start(run)
+	}
+	return nil
+}
+
+// stop stops a running monitor and waits for it to release its socket.
+func (s *MonitorSet) stop(run *monitorRun) {
+	run.cancel()
+	<-run.done
+	run.monitor.logger.Info("stopped monitoring")
+}
+
+// restore restarts stopped monitors with their configuration, in place.
+// Monitors that cannot be restarted are removed from the set, so that a
+// stopped monitor is never reported as up.
+func (s *MonitorSet) restore(runs []*monitorRun) error {
+	var errs []error
+	for _, old := range runs {
+		run, err := newMonitorRun(old.config, s.events)
+
+		s.mu.Lock()
+		if err != nil {
+			s.runs = slices.DeleteFunc(s.runs, func(r *monitorRun) bool { return r == old })
+		} else {
+			for i := range s.runs {
+				if s.runs[i] == old {
+					s.runs[i] = run
+				}
+			}
+		}
+		s.mu.Unlock()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s stopped: %v", old.config.Name, err))
+			continue
+		}
+		s.start(run)
+	}
+	return errors.Join(errs...)
+}
+
+// Wait blocks until every monitor has stopped after the set's context was
+// cancelled.
+func (s *MonitorSet) Wait() {
+	s.wg.Wait()
+}
+
+func newMonitorRun(cfg ServerConfig, bus *EventBus) (*monitorRun, error) {
+	monitor, err := NewACServerMonitor(cfg, bus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create monitor for %s: %v", cfg.Name, err)
+	}
+	if err := monitor.Connect(); err != nil {
+		monitor.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %v", cfg.Name, err)
+	}
+	return &monitorRun{monitor: monitor, config: cfg, done: make(chan struct{})}, nil
+}
+
+func (s *MonitorSet) start(run *monitorRun) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	run.cancel = cancel
+
+	cfg := run.config
+	run.monitor.logger.Info("monitoring server", "host", cfg.Host, "udp_port", cfg.UDPPort, "plugin_listen", cfg.PluginListen, "http_port", cfg.HTTPPort)
+	for _, target := range cfg.RelayTargets {
+		run.monitor.logger.Info("relaying plugin traffic", "plugin", target)
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(run.done)
+		run.monitor.Run(ctx)
+	}()
+}
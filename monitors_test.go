@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestRestoreRemovesMonitorsThatCannotRestart(t *testing.T) {
+	// The plugin port is taken by someone else while the monitor is stopped
+	taken := listenLoopback(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	set := NewMonitorSet(ctx, NewEventBus())
+
+	cfg := defaultServerConfig
+	cfg.PluginListen = taken.LocalAddr().String()
+	old := &monitorRun{config: cfg}
+	other := &monitorRun{config: ServerConfig{Name: "other"}}
+	set.runs = []*monitorRun{old, other}
+
+	if err := set.restore([]*monitorRun{old}); err == nil {
+		t.Fatal("restore reported no error for a monitor that cannot bind its port")
+	}
+	if len(set.runs) != 1 || set.runs[0] != other {
+		t.Errorf("runs after failed restore = %v, want only the untouched monitor", set.runs)
+	}
+}
+
+func TestApplyRebindsPluginPort(t *testing.T) {
+	probe, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := probe.LocalAddr().(*net.UDPAddr).Port
+	probe.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	set := NewMonitorSet(ctx, NewEventBus())
+	defer func() {
+		cancel()
+		set.Wait()
+	}()
+
+	cfg := defaultServerConfig
+	cfg.PluginListen = net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	if err := set.Apply([]ServerConfig{cfg}); err != nil {
+		t.Fatal(err)
+	}
+	first := set.Monitors()[0]
+
+	// A changed server is restarted on the same plugin port
+	cfg.RealtimeInterval = 250
+	if err := set.Apply([]ServerConfig{cfg}); err != nil {
+		t.Fatalf("reapplying with the same plugin port: %v", err)
+	}
+	if set.Monitors()[0] == first {
+		t.Error("changed server kept its monitor")
+	}
+
+	// A server that cannot start leaves the previous one running
+	broken := cfg
+	broken.RealtimeInterval = 500
+	broken.Host = "invalid host name"
+	if err := set.Apply([]ServerConfig{broken}); err == nil {
+		t.Fatal("unresolvable host accepted")
+	}
+	monitors := set.Monitors()
+	if len(monitors) != 1 || monitors[0].RealtimeInterval() != 250 {
+		t.Fatalf("monitors after failed reload = %v", monitors)
+	}
+	if got := monitors[0].conn.LocalAddr().(*net.UDPAddr).Port; got != port {
+		t.Errorf("restored monitor listens on port %d, want %d", got, port)
+	}
+}
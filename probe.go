@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const defaultProbeTimeout = 3 * time.Second
+
+// udpProbeWait is how long the UDP check waits for an ICMP port unreachable.
+// On a reachable host it arrives within a round trip.
+const udpProbeWait = 250 * time.Millisecond
+
+// ProbeHandler serves /probe?target=host:httpPort[&udp=port]. Modelled after
+// blackbox_exporter, it queries the /INFO endpoint of an arbitrary server on
+// demand and returns the derived metrics along with the probe outcome, so
+// that Prometheus relabelling decides which servers are checked.
+//
+// When udp is given, the probe also sends an empty datagram to that UDP
+// plugin port and reports whether it was refused. Nothing answers a port that
+// is open, so a port that is not refused is only known not to be closed.
+func ProbeHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	if target == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		http.Error(w, fmt.Sprintf("target must be host:port: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	udpPort := 0
+	if udp := r.URL.Query().Get("udp"); udp != "" {
+		port, err := strconv.ParseUint(udp, 10, 16)
+		if err != nil || port == 0 {
+			http.Error(w, "udp must be a port number", http.StatusBadRequest)
+			return
+		}
+		udpPort = int(port)
+	}
+
+	timeout := probeTimeout(r)
+	start := time.Now()
+
+	registry := prometheus.NewRegistry()
+	probeSuccess := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "Whether the probe was a success",
+	})
+	probeDuration := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "How long the probe took to complete in seconds",
+	})
+	registry.MustRegister(probeSuccess, probeDuration)
+
+	info, err := FetchServerInfo(r.Context(), target, timeout)
+	success := err == nil
+	registry.MustRegister(&probeCollector{descs: newServerInfoDescs(nil), info: info})
+
+	if udpPort != 0 {
+		udpReachable := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_udp_reachable",
+			Help: "Whether the UDP plugin port did not refuse a datagram (1 = not refused, 0 = refused)",
+		})
+		registry.MustRegister(udpReachable)
+
+		host, _, _ := net.SplitHostPort(target)
+		if err := probeUDP(net.JoinHostPort(host, strconv.Itoa(udpPort)), timeout-time.Since(start)); err != nil {
+			success = false
+		} else {
+			udpReachable.Set(1)
+		}
+	}
+
+	probeDuration.Set(time.Since(start).Seconds())
+	probeSuccess.Set(boolValue(success))
+
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probeCollector sends the /INFO metrics of a probed server.
+type probeCollector struct {
+	descs *serverInfoDescs
+	info  *ServerInfo
+}
+
+func (c *probeCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.descs.describe(ch)
+}
+
+func (c *probeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.descs.collect(ch, c.info != nil, c.info)
+}
+
+// probeTimeout honours the scrape timeout Prometheus sends, leaving some
+// headroom for the response.
+func probeTimeout(r *http.Request) time.Duration {
+	header := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds <= 0 {
+		return defaultProbeTimeout
+	}
+	timeout := time.Duration((seconds - 0.5) * float64(time.Second))
+	if timeout <= 0 || timeout > defaultProbeTimeout {
+		return defaultProbeTimeout
+	}
+	return timeout
+}
+
+// probeUDP sends an empty datagram to address and waits briefly for an ICMP
+// port unreachable, which surfaces as a refused read on a connected UDP
+// socket. The datagram carries no protocol message, so the server ignores it.
+func probeUDP(address string, timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("probe timed out before UDP check")
+	}
+	conn, err := net.DialTimeout("udp", address, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(nil); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(min(timeout, udpProbeWait)))
+	_, err = conn.Read(make([]byte, 1))
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return err
+	}
+	return nil
+}
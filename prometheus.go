@@ -1,115 +1,423 @@
 package main
 
 import (
-	"fmt"
-	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"acserver-exporter/acsp"
+)
+
+// serverInfoDescs describes the metrics derived from the /INFO endpoint. They
+// are shared by the exporter, where every metric carries a server label, and
+// the probe, where they carry none.
+type serverInfoDescs struct {
+	up                *prometheus.Desc
+	info              *prometheus.Desc
+	players           *prometheus.Desc
+	maxPlayers        *prometheus.Desc
+	session           *prometheus.Desc
+	carsAvailable     *prometheus.Desc
+	passwordProtected *prometheus.Desc
+	pickupMode        *prometheus.Desc
+	timeLeft          *prometheus.Desc
+}
+
+func newServerInfoDescs(labels []string) *serverInfoDescs {
+	infoLabels := append(append([]string{}, labels...), "server_name", "track", "powered_by")
+	return &serverInfoDescs{
+		up:                prometheus.NewDesc("ac_server_up", "Server availability (1 = up, 0 = down)", labels, nil),
+		info:              prometheus.NewDesc("ac_server_info", "Server name, track and version, always 1", infoLabels, nil),
+		players:           prometheus.NewDesc("ac_server_players", "Current number of connected players", labels, nil),
+		maxPlayers:        prometheus.NewDesc("ac_server_max_players", "Maximum player capacity", labels, nil),
+		session:           prometheus.NewDesc("ac_server_session", "Current session type (0=Booking, 1=Practice, 2=Qualifying, 3=Race)", labels, nil),
+		carsAvailable:     prometheus.NewDesc("ac_server_cars_available", "Number of available car models", labels, nil),
+		passwordProtected: prometheus.NewDesc("ac_server_password_protected", "Whether server requires password (1 = yes, 0 = no)", labels, nil),
+		pickupMode:        prometheus.NewDesc("ac_server_pickup_mode", "Whether pickup mode is enabled (1 = yes, 0 = no)", labels, nil),
+		timeLeft:          prometheus.NewDesc("ac_server_time_left", "Time remaining in current session (seconds)", labels, nil),
+	}
+}
+
+func (d *serverInfoDescs) describe(ch chan<- *prometheus.Desc) {
+	ch <- d.up
+	ch <- d.info
+	ch <- d.players
+	ch <- d.maxPlayers
+	ch <- d.session
+	ch <- d.carsAvailable
+	ch <- d.passwordProtected
+	ch <- d.pickupMode
+	ch <- d.timeLeft
+}
+
+// collect sends ac_server_up and the /INFO metrics of a server. A nil info
+// means there is no current /INFO data, in which case only ac_server_up is
+// sent.
+func (d *serverInfoDescs) collect(ch chan<- prometheus.Metric, up bool, info *ServerInfo, labelValues ...string) {
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, labelValues...)
+	}
+	gauge(d.up, boolValue(up))
+	if info == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(d.info, prometheus.GaugeValue, 1,
+		append(append([]string{}, labelValues...), info.Name, info.Track, info.PoweredBy)...)
+	gauge(d.players, float64(info.Clients))
+	gauge(d.maxPlayers, float64(info.MaxClients))
+	gauge(d.session, float64(info.Session))
+	gauge(d.carsAvailable, float64(len(info.Cars)))
+	gauge(d.passwordProtected, boolValue(info.Pass))
+	gauge(d.pickupMode, boolValue(info.PickupMode))
+	gauge(d.timeLeft, float64(info.TimeLeft))
+}
+
+var (
+	serverLabel     = []string{"server"}
+	carLabels       = []string{"server", "car_id", "driver", "car_model"}
+	driverLapLabels = []string{"server", "guid", "driver", "car_model", "track"}
+	resultLabels    = []string{"server", "session_type", "driver", "guid", "car_model"}
+
+	lapsCompletedDesc = prometheus.NewDesc("ac_server_lap_completed_total",
+		"Total laps completed", serverLabel, nil)
+	collisionsDesc = prometheus.NewDesc("ac_server_collisions_total",
+		"Total collision events, by type (env = environment, car = another car)", []string{"server", "type"}, nil)
+	impactSpeedDesc = prometheus.NewDesc("ac_server_collision_impact_speed_kmh",
+		"Collision impact speeds (km/h)", serverLabel, nil)
+	contactsDesc = prometheus.NewDesc("ac_server_driver_contacts_total",
+		"Total car-to-car contacts per driver, by role (caused = reported by the driver's car, received = reported by the other car). Drivers without a GUID have an empty guid and are told apart by name.",
+		[]string{"server", "guid", "driver", "role"}, nil)
+	connectionsDesc = prometheus.NewDesc("ac_server_connections_total",
+		"Total player connections", serverLabel, nil)
+	disconnectionsDesc = prometheus.NewDesc("ac_server_disconnections_total",
+		"Total player disconnections", serverLabel, nil)
+	relayPacketsDesc = prometheus.NewDesc("ac_server_relay_packets_total",
+		"Total packets relayed, by direction (to_plugins = server traffic sent to relay targets, to_server = relay target commands sent to the server)",
+		[]string{"server", "direction"}, nil)
+	foreignPacketsDesc = prometheus.NewDesc("ac_server_udp_foreign_packets_total",
+		"Total UDP packets dropped because they came neither from the server nor from a relay target", serverLabel, nil)
+	sessionsCompletedDesc = prometheus.NewDesc("ac_server_sessions_completed_total",
+		"Total sessions completed, by session type", []string{"server", "type"}, nil)
+
+	infoAgeDesc = prometheus.NewDesc("ac_server_info_age_seconds",
+		"Time since the last successful /INFO poll (seconds)", serverLabel, nil)
+	infoFetchesDesc = prometheus.NewDesc("ac_server_info_fetches_total",
+		"Total /INFO polls", serverLabel, nil)
+	infoFetchErrorsDesc = prometheus.NewDesc("ac_server_info_fetch_errors_total",
+		"Total failed /INFO polls", serverLabel, nil)
+	infoFetchDurationDesc = prometheus.NewDesc("ac_server_info_fetch_duration_seconds",
+		"Duration of the last /INFO poll (seconds)", serverLabel, nil)
+	infoUpDesc = prometheus.NewDesc("ac_server_info_up",
+		"Whether the last /INFO poll succeeded (1 = yes, 0 = no)", serverLabel, nil)
+	udpPacketAgeDesc = prometheus.NewDesc("ac_server_udp_last_packet_age_seconds",
+		"Time since the last UDP packet from the server (seconds)", serverLabel, nil)
+
+	lapTimeDesc = prometheus.NewDesc("ac_server_lap_time_seconds",
+		"Lap times per driver, car and track", driverLapLabels, nil)
+	bestLapDesc = prometheus.NewDesc("ac_server_best_lap_seconds",
+		"Fastest lap without cuts per driver, car and track", driverLapLabels, nil)
+	lastLapDesc = prometheus.NewDesc("ac_server_last_lap_seconds",
+		"Most recent lap per driver, car and track", driverLapLabels, nil)
+	lapCutsDesc = prometheus.NewDesc("ac_server_lap_cuts_total",
+		"Total track-limit cuts per driver, car and track", driverLapLabels, nil)
+	invalidLapsDesc = prometheus.NewDesc("ac_server_invalid_laps_total",
+		"Total laps with at least one cut per driver, car and track", driverLapLabels, nil)
+
+	gripLevelDesc = prometheus.NewDesc("ac_server_grip_level",
+		"Track grip level (0-1)", serverLabel, nil)
+	standingsPositionDesc = prometheus.NewDesc("ac_server_standings_position",
+		"Current leaderboard position", carLabels, nil)
+	standingsBestLapDesc = prometheus.NewDesc("ac_server_standings_best_lap_seconds",
+		"Best lap in the current session (seconds)", carLabels, nil)
+	standingsLapsDesc = prometheus.NewDesc("ac_server_standings_laps",
+		"Laps completed in the current session", carLabels, nil)
+	standingsFinishedDesc = prometheus.NewDesc("ac_server_standings_finished",
+		"Whether the car has finished the session (1 = yes, 0 = no)", carLabels, nil)
+
+	resultPositionDesc = prometheus.NewDesc("ac_server_session_result_position",
+		"Finishing position in the last completed session", resultLabels, nil)
+	resultTotalTimeDesc = prometheus.NewDesc("ac_server_session_result_total_time_seconds",
+		"Total time in the last completed session (seconds)", resultLabels, nil)
+	resultBestLapDesc = prometheus.NewDesc("ac_server_session_result_best_lap_seconds",
+		"Best lap in the last completed session (seconds)", resultLabels, nil)
+	resultLapsDesc = prometheus.NewDesc("ac_server_session_result_laps",
+		"Laps completed in the last completed session", resultLabels, nil)
+
+	carInfoDesc = prometheus.NewDesc("ac_server_car_info",
+		"Car slot model, skin and driver, always 1", []string{"server", "car_id", "model", "skin", "driver"}, nil)
+	carConnectedDesc = prometheus.NewDesc("ac_server_car_connected",
+		"Whether a driver occupies the car slot (1 = yes, 0 = no)", []string{"server", "car_id"}, nil)
+	playersByModelDesc = prometheus.NewDesc("ac_server_players_by_model",
+		"Connected players per car model", []string{"server", "model"}, nil)
+
+	carInfoRequestsDesc = prometheus.NewDesc("ac_server_car_info_requests_total",
+		"Total car info requests sent to the server", serverLabel, nil)
+	carInfoTimeoutsDesc = prometheus.NewDesc("ac_server_car_info_timeouts_total",
+		"Total car info requests the server did not answer in time", serverLabel, nil)
+	carInfoUnansweredDesc = prometheus.NewDesc("ac_server_car_info_unanswered_slots",
+		"Car slots whose last info request went unanswered", serverLabel, nil)
+
+	carSpeedDesc = prometheus.NewDesc("ac_server_car_speed_kmh",
+		"Current car speed (km/h)", carLabels, nil)
+	carRPMDesc = prometheus.NewDesc("ac_server_car_rpm",
+		"Current engine RPM", carLabels, nil)
+	carGearDesc = prometheus.NewDesc("ac_server_car_gear",
+		"Current gear (-1 = reverse, 0 = neutral)", carLabels, nil)
+	carSplinePositionDesc = prometheus.NewDesc("ac_server_car_spline_position",
+		"Normalized position along the track spline (0-1)", carLabels, nil)
+
+	subscriberLabel        = []string{"subscriber"}
+	eventSubscriptionsDesc = prometheus.NewDesc("ac_exporter_event_subscriptions",
+		"Current event bus subscriptions", subscriberLabel, nil)
+	eventQueueLengthDesc = prometheus.NewDesc("ac_exporter_event_queue_length",
+		"Events waiting in the subscribers' queues", subscriberLabel, nil)
+	eventsDeliveredDesc = prometheus.NewDesc("ac_exporter_events_delivered_total",
+		"Total events queued for subscribers", subscriberLabel, nil)
+	eventsDroppedDesc = prometheus.NewDesc("ac_exporter_events_dropped_total",
+		"Total events dropped because a subscriber's queue was full", subscriberLabel, nil)
 )
 
-var monitor *ACServerMonitor
+// metricGroups are the per-driver and per-car metric families that can be
+// disabled to limit the number of exported series.
+var metricGroups = []string{"contacts", "driver_laps", "standings", "session_results", "car_slots", "car_telemetry"}
+
+// Exporter is a prometheus.Collector for every monitored server. Metrics are
+// built from the monitors' state on each scrape; /INFO data comes from the
+// snapshot kept by each monitor's poller.
+type Exporter struct {
+	monitors *MonitorSet
+	events   *EventBus
+	info     *serverInfoDescs
+
+	mu       sync.RWMutex
+	disabled map[string]bool // metric groups
+}
+
+func NewExporter(monitors *MonitorSet, bus *EventBus) *Exporter {
+	return &Exporter{
+		monitors: monitors,
+		events:   bus,
+		info:     newServerInfoDescs(serverLabel),
+	}
+}
+
+// SetDisabledGroups replaces the set of metric groups left out of scrapes.
+func (e *Exporter) SetDisabledGroups(groups []string) {
+	disabled := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		disabled[group] = true
+	}
+
+	e.mu.Lock()
+	e.disabled = disabled
+	e.mu.Unlock()
+}
+
+// enabledGroups returns whether a metric group is currently exported.
+func (e *Exporter) enabledGroups() func(group string) bool {
+	e.mu.RLock()
+	disabled := e.disabled
+	e.mu.RUnlock()
+	return func(group string) bool { return !disabled[group] }
+}
 
-func InitPrometheusMetrics(m *ACServerMonitor) {
-	monitor = m
+func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	e.info.describe(ch)
+	for _, desc := range []*prometheus.Desc{
+		lapsCompletedDesc, collisionsDesc, impactSpeedDesc, contactsDesc,
+		connectionsDesc, disconnectionsDesc, relayPacketsDesc, foreignPacketsDesc, sessionsCompletedDesc,
+		infoAgeDesc, infoFetchesDesc, infoFetchErrorsDesc, infoFetchDurationDesc, infoUpDesc, udpPacketAgeDesc,
+		lapTimeDesc, bestLapDesc, lastLapDesc, lapCutsDesc, invalidLapsDesc,
+		gripLevelDesc, standingsPositionDesc, standingsBestLapDesc, standingsLapsDesc, standingsFinishedDesc,
+		resultPositionDesc, resultTotalTimeDesc, resultBestLapDesc, resultLapsDesc,
+		carInfoDesc, carConnectedDesc, playersByModelDesc,
+		carInfoRequestsDesc, carInfoTimeoutsDesc, carInfoUnansweredDesc,
+		carSpeedDesc, carRPMDesc, carGearDesc, carSplinePositionDesc,
+		eventSubscriptionsDesc, eventQueueLengthDesc, eventsDeliveredDesc, eventsDroppedDesc,
+	} {
+		ch <- desc
+	}
 }
 
-func PrometheusHandler(m *ACServerMonitor) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Refresh stats before serving metrics
-		if err := FetchHTTPInfo(m); err == nil {
-			// Successfully fetched HTTP info
+func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	enabled := e.enabledGroups()
+	for _, m := range e.monitors.Monitors() {
+		e.collectMonitor(ch, m, enabled)
+	}
+	for _, stats := range e.events.Stats() {
+		ch <- prometheus.MustNewConstMetric(eventSubscriptionsDesc, prometheus.GaugeValue, float64(stats.Subscriptions), stats.Name)
+		ch <- prometheus.MustNewConstMetric(eventQueueLengthDesc, prometheus.GaugeValue, float64(stats.Queued), stats.Name)
+		ch <- prometheus.MustNewConstMetric(eventsDeliveredDesc, prometheus.CounterValue, float64(stats.Delivered), stats.Name)
+		ch <- prometheus.MustNewConstMetric(eventsDroppedDesc, prometheus.CounterValue, float64(stats.Dropped), stats.Name)
+	}
+}
+
+// collectMonitor sends the metrics of a single monitored server, each
+// labelled with the server name.
+func (e *Exporter) collectMonitor(ch chan<- prometheus.Metric, m *ACServerMonitor, enabled func(group string) bool) {
+	server := m.name
+
+	now := time.Now()
+	liveness := m.Liveness()
+	up := m.Up(liveness, now)
+
+	// Cached /INFO data is dropped once it is older than the staleness
+	// threshold rather than reported as current.
+	var info *ServerInfo
+	if snapshot := liveness.Info; snapshot != nil {
+		age := now.Sub(snapshot.FetchedAt)
+		if age <= m.staleAfter {
+			info = snapshot.Info
 		}
-		
-		var metrics strings.Builder
-		
-		// Help text and type declarations
-		metrics.WriteString("# HELP ac_server_up Server availability (1 = up, 0 = down)\n")
-		metrics.WriteString("# TYPE ac_server_up gauge\n")
-		
-		metrics.WriteString("# HELP ac_server_players Current number of connected players\n")
-		metrics.WriteString("# TYPE ac_server_players gauge\n")
-		
-		metrics.WriteString("# HELP ac_server_max_players Maximum player capacity\n")
-		metrics.WriteString("# TYPE ac_server_max_players gauge\n")
-		
-		metrics.WriteString("# HELP ac_server_session Current session type (0=Booking, 1=Practice, 2=Qualifying, 3=Race)\n")
-		metrics.WriteString("# TYPE ac_server_session gauge\n")
-		
-		metrics.WriteString("# HELP ac_server_cars_available Number of available car models\n")
-		metrics.WriteString("# TYPE ac_server_cars_available gauge\n")
-		
-		metrics.WriteString("# HELP ac_server_password_protected Whether server requires password\n")
-		metrics.WriteString("# TYPE ac_server_password_protected gauge\n")
-		
-		metrics.WriteString("# HELP ac_server_pickup_mode Whether pickup mode is enabled\n")
-		metrics.WriteString("# TYPE ac_server_pickup_mode gauge\n")
-		
-		metrics.WriteString("# HELP ac_server_time_left Time remaining in current session (seconds)\n")
-		metrics.WriteString("# TYPE ac_server_time_left gauge\n")
-		
-		metrics.WriteString("# HELP ac_server_lap_completed_total Total laps completed\n")
-		metrics.WriteString("# TYPE ac_server_lap_completed_total counter\n")
-		
-		metrics.WriteString("# HELP ac_server_collisions_total Total collision events\n")
-		metrics.WriteString("# TYPE ac_server_collisions_total counter\n")
-		
-		metrics.WriteString("# HELP ac_server_connections_total Total player connections\n")
-		metrics.WriteString("# TYPE ac_server_connections_total counter\n")
-		
-		metrics.WriteString("# HELP ac_server_disconnections_total Total player disconnections\n")
-		metrics.WriteString("# TYPE ac_server_disconnections_total counter\n")
-		
-		m.mu.RLock()
-		info := m.serverInfo
-		m.mu.RUnlock()
-		
-		if info != nil {
-			// Server is up
-			labels := fmt.Sprintf(`server_name="%s",track="%s",powered_by="%s"`,
-				escapeLabelValue(info.Name),
-				escapeLabelValue(info.Track),
-				escapeLabelValue(info.PoweredBy))
-			
-			metrics.WriteString(fmt.Sprintf("ac_server_up{%s} 1\n", labels))
-			metrics.WriteString(fmt.Sprintf("ac_server_players{%s} %d\n", labels, info.Clients))
-			metrics.WriteString(fmt.Sprintf("ac_server_max_players{%s} %d\n", labels, info.MaxClients))
-			metrics.WriteString(fmt.Sprintf("ac_server_session{%s} %d\n", labels, info.Session))
-			metrics.WriteString(fmt.Sprintf("ac_server_cars_available{%s} %d\n", labels, len(info.Cars)))
-			
-			passwordProtected := 0
-			if info.Pass {
-				passwordProtected = 1
+		ch <- prometheus.MustNewConstMetric(infoAgeDesc, prometheus.GaugeValue, age.Seconds(), server)
+	}
+	e.info.collect(ch, up, info, server)
+	ch <- prometheus.MustNewConstMetric(infoUpDesc, prometheus.GaugeValue, boolValue(liveness.InfoUp), server)
+	if !liveness.LastPacket.IsZero() {
+		ch <- prometheus.MustNewConstMetric(udpPacketAgeDesc, prometheus.GaugeValue, now.Sub(liveness.LastPacket).Seconds(), server)
+	}
+
+	// Counters (these persist across scrapes)
+	m.metricsLock.RLock()
+	counter := func(desc *prometheus.Desc, value int64, labelValues ...string) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value), append([]string{server}, labelValues...)...)
+	}
+	counter(lapsCompletedDesc, m.totalLaps)
+	for _, eventType := range []acsp.ClientEventType{acsp.CollisionWithEnv, acsp.CollisionWithCar} {
+		collisions := prometheus.MustNewConstMetric(collisionsDesc, prometheus.CounterValue,
+			float64(m.collisions[eventType]), server, eventType.String())
+		if exemplar := m.collisionExemplars[eventType]; exemplar != nil {
+			if withExemplar, err := prometheus.NewMetricWithExemplars(collisions, *exemplar); err == nil {
+				collisions = withExemplar
 			}
-			metrics.WriteString(fmt.Sprintf("ac_server_password_protected{%s} %d\n", labels, passwordProtected))
-			
-			pickupMode := 0
-			if info.PickupMode {
-				pickupMode = 1
+		}
+		ch <- collisions
+	}
+	ch <- m.impactSpeeds.metric(impactSpeedDesc, server)
+	if enabled("contacts") {
+		for _, contacts := range m.contacts {
+			counter(contactsDesc, int64(contacts.Caused), contacts.GUID, contacts.DriverName, "caused")
+			counter(contactsDesc, int64(contacts.Received), contacts.GUID, contacts.DriverName, "received")
+		}
+	}
+	counter(connectionsDesc, m.totalConnections)
+	counter(disconnectionsDesc, m.totalDisconnections)
+	counter(relayPacketsDesc, m.relayedToPlugins, "to_plugins")
+	counter(relayPacketsDesc, m.relayedToServer, "to_server")
+	counter(foreignPacketsDesc, m.foreignPackets)
+	for sessionType, count := range m.sessionsCompleted {
+		counter(sessionsCompletedDesc, count, sessionType)
+	}
+	counter(infoFetchesDesc, m.infoFetches)
+	counter(infoFetchErrorsDesc, m.infoFetchErrors)
+	if m.infoFetches > 0 {
+		ch <- prometheus.MustNewConstMetric(infoFetchDurationDesc, prometheus.GaugeValue, m.infoFetchDuration.Seconds(), server)
+	}
+
+	if enabled("driver_laps") {
+		for key, stats := range m.lapStats {
+			labelValues := []string{server, key.GUID, stats.DriverName, key.CarModel, key.Track}
+			ch <- stats.LapTimes.metric(lapTimeDesc, labelValues...)
+			if stats.BestLap > 0 {
+				ch <- prometheus.MustNewConstMetric(bestLapDesc, prometheus.GaugeValue, float64(stats.BestLap)/1000, labelValues...)
 			}
-			metrics.WriteString(fmt.Sprintf("ac_server_pickup_mode{%s} %d\n", labels, pickupMode))
-			metrics.WriteString(fmt.Sprintf("ac_server_time_left{%s} %d\n", labels, info.TimeLeft))
-		} else {
-			// Server is down or unreachable
-			metrics.WriteString("ac_server_up 0\n")
-			metrics.WriteString("ac_server_players 0\n")
-			metrics.WriteString("ac_server_max_players 0\n")
+			ch <- prometheus.MustNewConstMetric(lastLapDesc, prometheus.GaugeValue, float64(stats.LastLap)/1000, labelValues...)
+			ch <- prometheus.MustNewConstMetric(lapCutsDesc, prometheus.CounterValue, float64(stats.Cuts), labelValues...)
+			ch <- prometheus.MustNewConstMetric(invalidLapsDesc, prometheus.CounterValue, float64(stats.InvalidLaps), labelValues...)
+		}
+	}
+	m.metricsLock.RUnlock()
+
+	requests, timeouts, unanswered := m.carInfo.stats()
+	ch <- prometheus.MustNewConstMetric(carInfoRequestsDesc, prometheus.CounterValue, float64(requests), server)
+	ch <- prometheus.MustNewConstMetric(carInfoTimeoutsDesc, prometheus.CounterValue, float64(timeouts), server)
+	ch <- prometheus.MustNewConstMetric(carInfoUnansweredDesc, prometheus.GaugeValue, float64(unanswered), server)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Live standings from the last completed lap
+	if m.standings != nil {
+		ch <- prometheus.MustNewConstMetric(gripLevelDesc, prometheus.GaugeValue, float64(m.gripLevel), server)
+	}
+	if enabled("standings") {
+		for _, st := range m.standings {
+			labelValues := carLabelValues(server, st.CarID, m.cars[st.CarID])
+			ch <- prometheus.MustNewConstMetric(standingsPositionDesc, prometheus.GaugeValue, float64(st.Position), labelValues...)
+			if st.HasBestLap() {
+				ch <- prometheus.MustNewConstMetric(standingsBestLapDesc, prometheus.GaugeValue, float64(st.BestLap)/1000, labelValues...)
+			}
+			ch <- prometheus.MustNewConstMetric(standingsLapsDesc, prometheus.GaugeValue, float64(st.Laps), labelValues...)
+			ch <- prometheus.MustNewConstMetric(standingsFinishedDesc, prometheus.GaugeValue, boolValue(st.Completed), labelValues...)
+		}
+	}
+
+	// Final classification of the last completed session
+	if m.lastResults != nil && enabled("session_results") {
+		sessionType := strings.ToLower(m.lastResults.SessionType())
+		for _, c := range m.lastResults.Classification() {
+			labelValues := []string{server, sessionType, c.DriverName, c.DriverGUID, c.CarModel}
+			ch <- prometheus.MustNewConstMetric(resultPositionDesc, prometheus.GaugeValue, float64(c.Position), labelValues...)
+			ch <- prometheus.MustNewConstMetric(resultTotalTimeDesc, prometheus.GaugeValue, float64(c.TotalTime)/1000, labelValues...)
+			if c.HasBestLap() {
+				ch <- prometheus.MustNewConstMetric(resultBestLapDesc, prometheus.GaugeValue, float64(c.BestLap)/1000, labelValues...)
+			}
+			ch <- prometheus.MustNewConstMetric(resultLapsDesc, prometheus.GaugeValue, float64(c.LapsCompleted), labelValues...)
+		}
+	}
+
+	// Car slots while the server is up; an empty server sends no UDP
+	// traffic, but its slots are still worth reporting
+	if up && enabled("car_slots") {
+		playersByModel := make(map[string]int)
+		for carID, car := range m.cars {
+			id := strconv.Itoa(int(carID))
+			ch <- prometheus.MustNewConstMetric(carInfoDesc, prometheus.GaugeValue, 1, server, id, car.CarModel, car.CarSkin, car.DriverName)
+			ch <- prometheus.MustNewConstMetric(carConnectedDesc, prometheus.GaugeValue, boolValue(car.IsConnected), server, id)
+			if car.CarModel == "" {
+				continue
+			}
+			if car.IsConnected {
+				playersByModel[car.CarModel]++
+			} else if _, ok := playersByModel[car.CarModel]; !ok {
+				playersByModel[car.CarModel] = 0
+			}
+		}
+		for model, players := range playersByModel {
+			ch <- prometheus.MustNewConstMetric(playersByModelDesc, prometheus.GaugeValue, float64(players), server, model)
+		}
+	}
+	// Realtime telemetry, unless the server has gone quiet
+	udpFresh := !liveness.LastPacket.IsZero() && now.Sub(liveness.LastPacket) <= m.staleAfter
+	if udpFresh && enabled("car_telemetry") {
+		for carID, t := range m.telemetry {
+			if !m.telemetryCurrent(t, now) {
+				continue
+			}
+			labelValues := carLabelValues(server, carID, m.cars[carID])
+			ch <- prometheus.MustNewConstMetric(carSpeedDesc, prometheus.GaugeValue, t.SpeedKMH(), labelValues...)
+			ch <- prometheus.MustNewConstMetric(carRPMDesc, prometheus.GaugeValue, float64(t.EngineRPM), labelValues...)
+			ch <- prometheus.MustNewConstMetric(carGearDesc, prometheus.GaugeValue, float64(t.DisplayGear()), labelValues...)
+			ch <- prometheus.MustNewConstMetric(carSplinePositionDesc, prometheus.GaugeValue, float64(t.NormalizedSplinePos), labelValues...)
 		}
-		
-		// Counters (these persist across scrapes)
-		m.metricsLock.RLock()
-		metrics.WriteString(fmt.Sprintf("ac_server_lap_completed_total %d\n", m.totalLaps))
-		metrics.WriteString(fmt.Sprintf("ac_server_collisions_total %d\n", m.totalCollisions))
-		metrics.WriteString(fmt.Sprintf("ac_server_connections_total %d\n", m.totalConnections))
-		metrics.WriteString(fmt.Sprintf("ac_server_disconnections_total %d\n", m.totalDisconnections))
-		m.metricsLock.RUnlock()
-		
-		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
-		w.Write([]byte(metrics.String()))
 	}
 }
 
-func escapeLabelValue(s string) string {
-	s = strings.ReplaceAll(s, "\\", "\\\\")
-	s = strings.ReplaceAll(s, "\"", "\\\"")
-	s = strings.ReplaceAll(s, "\n", "\\n")
-	return s
+func carLabelValues(server string, carID uint8, car *CarInfo) []string {
+	driver, model := "", ""
+	if car != nil {
+		driver, model = car.DriverName, car.CarModel
+	}
+	return []string{server, strconv.Itoa(int(carID)), driver, model}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
 }
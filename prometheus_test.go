@@ -0,0 +1,87 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fqNamePattern = regexp.MustCompile(`fqName: "([^"]+)"`)
+
+// testMonitor returns a monitor on a free loopback port that is not running.
+func testMonitor(t *testing.T) *ACServerMonitor {
+	t.Helper()
+	cfg := defaultServerConfig
+	cfg.PluginListen = "127.0.0.1:0"
+	m, err := NewACServerMonitor(cfg, NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(m.Close)
+	return m
+}
+
+// collectCounts collects the metrics of m and counts them by name.
+func collectCounts(m *ACServerMonitor) map[string]int {
+	e := NewExporter(nil, NewEventBus())
+	ch := make(chan prometheus.Metric)
+	go func() {
+		e.collectMonitor(ch, m, func(string) bool { return true })
+		close(ch)
+	}()
+
+	counts := make(map[string]int)
+	for metric := range ch {
+		if match := fqNamePattern.FindStringSubmatch(metric.Desc().String()); match != nil {
+			counts[match[1]]++
+		}
+	}
+	return counts
+}
+
+func TestCollectCarSlotsWithoutUDPTraffic(t *testing.T) {
+	m := testMonitor(t)
+	m.info = &InfoSnapshot{Info: &ServerInfo{}, FetchedAt: time.Now()}
+	m.infoUp = true
+	m.cars[0] = &CarInfo{CarID: 0, CarModel: "ks_mazda_mx5_cup"}
+	m.cars[1] = &CarInfo{CarID: 1, CarModel: "ks_mazda_mx5_cup"}
+	m.telemetry[0] = &CarTelemetry{UpdatedAt: time.Now()}
+
+	counts := collectCounts(m)
+	if counts["ac_server_car_connected"] != 2 || counts["ac_server_car_info"] != 2 {
+		t.Errorf("car slot series = %d connected, %d info, want 2 each", counts["ac_server_car_connected"], counts["ac_server_car_info"])
+	}
+	if counts["ac_server_players_by_model"] != 1 {
+		t.Errorf("players by model series = %d, want 1", counts["ac_server_players_by_model"])
+	}
+	if counts["ac_server_car_speed_kmh"] != 0 {
+		t.Error("telemetry exported without UDP traffic")
+	}
+
+	// Nothing is reported about the slots of a server that is down
+	m.infoUp = false
+	if counts := collectCounts(m); counts["ac_server_car_connected"] != 0 {
+		t.Errorf("car slots exported while the server is down")
+	}
+}
+
+func TestCollectSkipsStaleTelemetry(t *testing.T) {
+	m := testMonitor(t)
+	now := time.Now()
+	m.lastPacket = now
+	m.realtimeInterval = 1000
+	m.telemetry[0] = &CarTelemetry{UpdatedAt: now}
+	m.telemetry[1] = &CarTelemetry{UpdatedAt: now.Add(-time.Minute)}
+
+	if got := collectCounts(m)["ac_server_car_speed_kmh"]; got != 1 {
+		t.Errorf("speed series = %d, want only the car still sending updates", got)
+	}
+
+	// No car updates are expected while they are disabled
+	m.realtimeInterval = 0
+	if got := collectCounts(m)["ac_server_car_speed_kmh"]; got != 0 {
+		t.Errorf("speed series = %d with car updates disabled, want 0", got)
+	}
+}
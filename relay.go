@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net"
+)
+
+// The AC server only sends plugin traffic to a single address, the
+// monitor's plugin listen address. The monitor can relay it to further
+// plugins: every packet received from the server is forwarded to each relay
+// target, and every packet received from a relay target is forwarded to the
+// server as a command. Relay targets send their commands to the plugin
+// listen address, as if it were the server.
+
+// resolveRelayTargets resolves the downstream plugin addresses to relay
+// server traffic to.
+func resolveRelayTargets(addresses []string) ([]*net.UDPAddr, error) {
+	targets := make([]*net.UDPAddr, 0, len(addresses))
+	for _, address := range addresses {
+		addr, err := net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve relay address %q: %v", address, err)
+		}
+		targets = append(targets, addr)
+	}
+	return targets, nil
+}
+
+// isServer reports whether addr is the server's plugin address, the only
+// source of protocol events.
+func (m *ACServerMonitor) isServer(addr *net.UDPAddr) bool {
+	return m.serverAddr.Port == addr.Port && m.serverAddr.IP.Equal(addr.IP)
+}
+
+func (m *ACServerMonitor) isRelayTarget(addr *net.UDPAddr) bool {
+	for _, target := range m.relayTargets {
+		if target.Port == addr.Port && target.IP.Equal(addr.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// relayToPlugins forwards a packet received from the server to every relay
+// target.
+func (m *ACServerMonitor) relayToPlugins(data []byte) {
+	for _, target := range m.relayTargets {
+		if _, err := m.conn.WriteToUDP(data, target); err != nil {
+			m.logger.Warn("relaying to plugin failed", "plugin", target.String(), "err", err)
+			continue
+		}
+		m.metricsLock.Lock()
+		m.relayedToPlugins++
+		m.metricsLock.Unlock()
+	}
+}
+
+// relayToServer forwards a command received from a relay target to the
+// server.
+func (m *ACServerMonitor) relayToServer(data []byte, from *net.UDPAddr) {
+	if err := m.send(data); err != nil {
+		m.logger.Warn("relaying plugin command failed", "plugin", from.String(), "err", err)
+		return
+	}
+	m.metricsLock.Lock()
+	m.relayedToServer++
+	m.metricsLock.Unlock()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"acserver-exporter/acsp"
+)
+
+// listenLoopback opens a UDP socket on a free loopback port.
+func listenLoopback(t *testing.T) *net.UDPConn {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// receive reads one datagram and reports where it came from.
+func receive(t *testing.T, conn *net.UDPConn) ([]byte, *net.UDPAddr) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buffer := make([]byte, 2048)
+	n, from, err := conn.ReadFromUDP(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer[:n], from
+}
+
+func TestRelayLoopback(t *testing.T) {
+	server := listenLoopback(t)
+	plugin := listenLoopback(t)
+	serverAddr := server.LocalAddr().(*net.UDPAddr)
+
+	cfg := defaultServerConfig
+	cfg.Host = "127.0.0.1"
+	cfg.UDPPort = serverAddr.Port
+	cfg.PluginListen = "127.0.0.1:0"
+	cfg.RelayTargets = []string{plugin.LocalAddr().String()}
+	m, err := NewACServerMonitor(cfg, NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Listen(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		m.Close()
+		<-done
+	})
+	exporter := m.conn.LocalAddr().(*net.UDPAddr)
+
+	// Server traffic sent to the plugin address reaches the plugin
+	event := []byte{acsp.MsgClientLoaded, 3}
+	if _, err := server.WriteToUDP(event, exporter); err != nil {
+		t.Fatal(err)
+	}
+	got, from := receive(t, plugin)
+	if !bytes.Equal(got, event) {
+		t.Errorf("plugin received %v, want %v", got, event)
+	}
+	if from.Port != exporter.Port {
+		t.Errorf("plugin received traffic from port %d, want the exporter's %d", from.Port, exporter.Port)
+	}
+
+	// Plugin commands sent to the plugin address reach the server
+	command := acsp.BroadcastChat("hello")
+	if _, err := plugin.WriteToUDP(command, exporter); err != nil {
+		t.Fatal(err)
+	}
+	got, from = receive(t, server)
+	if !bytes.Equal(got, command) {
+		t.Errorf("server received %v, want %v", got, command)
+	}
+	if from.Port != exporter.Port {
+		t.Errorf("server received traffic from port %d, want the exporter's %d", from.Port, exporter.Port)
+	}
+
+	m.metricsLock.RLock()
+	defer m.metricsLock.RUnlock()
+	if m.relayedToPlugins != 1 || m.relayedToServer != 1 {
+		t.Errorf("relayed %d packets to plugins and %d to the server, want 1 each", m.relayedToPlugins, m.relayedToServer)
+	}
+}
+
+func TestMonitorListensOnPluginAddress(t *testing.T) {
+	// Find a free port, then have the monitor bind it
+	probe := listenLoopback(t)
+	port := probe.LocalAddr().(*net.UDPAddr).Port
+	probe.Close()
+
+	cfg := defaultServerConfig
+	cfg.PluginListen = net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	m, err := NewACServerMonitor(cfg, NewEventBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if got := m.conn.LocalAddr().(*net.UDPAddr).Port; got != port {
+		t.Errorf("listening on port %d, want %d", got, port)
+	}
+
+	if _, err := NewACServerMonitor(cfg, NewEventBus()); err == nil {
+		t.Error("second monitor bound the same plugin address")
+	}
+}
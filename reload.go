@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// Reloader re-reads the configuration and applies it to the running
+// exporter: monitors are added, replaced or removed and the disabled metric
+// groups and log level are updated. The listen address, admin token,
+// shutdown timeout, stats interval and log format only change on restart.
+type Reloader struct {
+	args     []string
+	monitors *MonitorSet
+	exporter *Exporter
+
+	mu     sync.Mutex
+	config *Config
+}
+
+func NewReloader(args []string, config *Config, monitors *MonitorSet, exporter *Exporter) *Reloader {
+	return &Reloader{args: args, config: config, monitors: monitors, exporter: exporter}
+}
+
+// Reload loads the configuration again and applies it. On error the running
+// configuration is kept.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	config, _, err := LoadConfig(r.args)
+	if err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+	if config.ListenAddress != r.config.ListenAddress || config.AdminToken != r.config.AdminToken ||
+		config.ShutdownTimeout != r.config.ShutdownTimeout || config.StatsInterval != r.config.StatsInterval ||
+		config.LogFormat != r.config.LogFormat {
+		slog.Warn("changes to listen_address, admin_token, shutdown_timeout, stats_interval and log_format take effect after a restart")
+	}
+
+	if err := r.monitors.Apply(config.Servers); err != nil {
+		return err
+	}
+	r.exporter.SetDisabledGroups(config.DisabledMetrics)
+	level, _ := parseLogLevel(config.LogLevel)
+	logLevel.Set(level)
+
+	// Keep the settings that were not applied, so that the restart warning
+	// is not repeated on every reload
+	config.ListenAddress = r.config.ListenAddress
+	config.AdminToken = r.config.AdminToken
+	config.ShutdownTimeout = r.config.ShutdownTimeout
+	config.StatsInterval = r.config.StatsInterval
+	config.LogFormat = r.config.LogFormat
+	r.config = config
+
+	slog.Info("configuration reloaded", "servers", len(config.Servers))
+	return nil
+}
+
+// ReloadHandler serves POST /-/reload.
+func ReloadHandler(reloader *Reloader) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := reloader.Reload(); err != nil {
+			slog.Error("reload failed", "err", err)
+			http.Error(w, fmt.Sprintf("reload failed: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("OK\n"))
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// noLapTime is what the server writes for BestLap when a driver never set a
+// valid lap.
+const noLapTime = 999999999
+
+// SessionResults is the subset of the results JSON written by the server at
+// the end of each session that the exporter uses.
+type SessionResults struct {
+	TrackName    string        `json:"TrackName"`
+	TrackConfig  string        `json:"TrackConfig"`
+	Type         string        `json:"Type"`
+	DurationSecs int           `json:"DurationSecs"`
+	RaceLaps     int           `json:"RaceLaps"`
+	Result       []ResultEntry `json:"Result"`
+	Laps         []ResultLap   `json:"Laps"`
+}
+
+// ResultEntry is a row of the final classification, in finishing order.
+type ResultEntry struct {
+	DriverName string `json:"DriverName"`
+	DriverGUID string `json:"DriverGuid"`
+	CarID      int    `json:"CarId"`
+	CarModel   string `json:"CarModel"`
+	BestLap    int64  `json:"BestLap"`   // ms
+	TotalTime  int64  `json:"TotalTime"` // ms
+}
+
+// ResultLap is a single lap from the session lap log.
+type ResultLap struct {
+	DriverName string `json:"DriverName"`
+	DriverGUID string `json:"DriverGuid"`
+	CarID      int    `json:"CarId"`
+	CarModel   string `json:"CarModel"`
+	LapTime    int64  `json:"LapTime"` // ms
+	Cuts       int    `json:"Cuts"`
+}
+
+// Classification is a driver's final result with their lap count.
+type Classification struct {
+	Position int
+	ResultEntry
+	LapsCompleted int
+}
+
+// HasBestLap reports whether the driver set a valid lap.
+func (c *Classification) HasBestLap() bool {
+	return c.BestLap > 0 && c.BestLap < noLapTime
+}
+
+// Classification returns the final classification. Empty car slots (no name
+// and no GUID) are skipped.
+func (r *SessionResults) Classification() []Classification {
+	laps := make(map[resultDriver]int)
+	for _, lap := range r.Laps {
+		laps[newResultDriver(lap.DriverGUID, lap.DriverName)]++
+	}
+
+	var classification []Classification
+	for _, entry := range r.Result {
+		if entry.DriverGUID == "" && entry.DriverName == "" {
+			continue
+		}
+		classification = append(classification, Classification{
+			Position:      len(classification) + 1,
+			ResultEntry:   entry,
+			LapsCompleted: laps[newResultDriver(entry.DriverGUID, entry.DriverName)],
+		})
+	}
+	return classification
+}
+
+// resultDriver identifies a driver in a results file: by GUID, or by name for
+// drivers without one.
+type resultDriver struct {
+	GUID string
+	Name string // only without a GUID
+}
+
+func newResultDriver(guid, name string) resultDriver {
+	if guid == "" {
+		return resultDriver{Name: name}
+	}
+	return resultDriver{GUID: guid}
+}
+
+// SessionType returns the session type in the same form as
+// acsp.SessionType.String.
+func (r *SessionResults) SessionType() string {
+	switch strings.ToUpper(r.Type) {
+	case "BOOKING":
+		return "Booking"
+	case "PRACTICE":
+		return "Practice"
+	case "QUALIFY":
+		return "Qualifying"
+	case "RACE":
+		return "Race"
+	}
+	return "Unknown"
+}
+
+// LoadSessionResults reads the results file named by an END_SESSION message
+// from dir. Only the base name of reportFile is used, since the server
+// reports it relative to its own working directory.
+func LoadSessionResults(dir string, reportFile string) (*SessionResults, error) {
+	name := filepath.Base(strings.ReplaceAll(reportFile, "\\", "/"))
+	path := filepath.Join(dir, name)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results: %v", err)
+	}
+
+	var results SessionResults
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse results %s: %v", path, err)
+	}
+	return &results, nil
+}
+
+// endedSessionsQueueSize is the number of ended sessions that may wait for
+// their results to be loaded.
+const endedSessionsQueueSize = 16
+
+// endedSession is a session that ended, as reported by the server.
+type endedSession struct {
+	sessionType string // empty if no session info was received
+	reportFile  string
+}
+
+// LoadResults loads the results of ended sessions queued by handleEndSession
+// and records them until ctx is cancelled.
+func (m *ACServerMonitor) LoadResults(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ended := <-m.endedSessions:
+			results, err := LoadSessionResults(m.resultsDir, ended.reportFile)
+			if err != nil {
+				m.logger.Warn("session results unavailable", "report_file", ended.reportFile, "err", err)
+			}
+			m.endSession(ended, results)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+func TestClassificationWithoutGUID(t *testing.T) {
+	results := &SessionResults{
+		Result: []ResultEntry{
+			{DriverName: "Alice", CarID: 0, BestLap: 90000},
+			{DriverName: "Bob", DriverGUID: "76561198000000001", CarID: 1, BestLap: 91000},
+			{CarID: 2, BestLap: noLapTime},
+			{DriverName: "Carol", CarID: 3, BestLap: 92000},
+		},
+		Laps: []ResultLap{
+			{DriverName: "Alice", CarID: 0, LapTime: 90000},
+			{DriverName: "Alice", CarID: 0, LapTime: 91000},
+			{DriverName: "Bob", DriverGUID: "76561198000000001", CarID: 1, LapTime: 91000},
+			{DriverName: "Carol", CarID: 3, LapTime: 92000},
+		},
+	}
+
+	want := []struct {
+		name string
+		laps int
+	}{
+		{"Alice", 2},
+		{"Bob", 1},
+		{"Carol", 1},
+	}
+	got := results.Classification()
+	if len(got) != len(want) {
+		t.Fatalf("got %d classified drivers, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		c := got[i]
+		if c.Position != i+1 || c.DriverName != w.name || c.LapsCompleted != w.laps {
+			t.Errorf("position %d = %s with %d laps (P%d), want %s with %d laps", i+1, c.DriverName, c.LapsCompleted, c.Position, w.name, w.laps)
+		}
+	}
+}
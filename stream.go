@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// streamQueueSize is the number of events a stream client may lag
+	// behind before events are dropped for it.
+	streamQueueSize = 256
+	// streamKeepalive is the interval of SSE comments and WebSocket pings
+	// sent to idle clients, so that proxies do not close the connection.
+	streamKeepalive = 30 * time.Second
+	// streamWriteTimeout bounds a single write to a client.
+	streamWriteTimeout = 10 * time.Second
+)
+
+// streamedEvents are the event types sent when the client does not select
+// any. car_info and session_info follow from polling rather than from
+// something happening on track, so they must be asked for.
+var streamedEvents = []EventType{
+	EventNewSession, EventEndSession, EventNewConnection, EventConnectionClosed, EventClientLoaded,
+	EventLapCompleted, EventCollision, EventChat, EventVersion, EventError,
+}
+
+var allEvents = append([]EventType{EventSessionInfo, EventCarInfo}, streamedEvents...)
+
+var upgrader = websocket.Upgrader{
+	// The stream is read-only, so overlays may be served from anywhere.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// eventFilter selects the events sent to a stream client.
+type eventFilter struct {
+	servers map[string]bool // all if empty
+	types   map[EventType]bool
+	cars    map[uint8]bool // all if empty
+}
+
+// parseEventFilter reads the server, type and car query parameters. Each
+// may be repeated or hold a comma-separated list.
+func parseEventFilter(query url.Values) (*eventFilter, error) {
+	filter := &eventFilter{
+		servers: make(map[string]bool),
+		types:   make(map[EventType]bool),
+		cars:    make(map[uint8]bool),
+	}
+	for _, server := range queryList(query, "server") {
+		filter.servers[server] = true
+	}
+	for _, name := range queryList(query, "type") {
+		eventType := EventType(name)
+		if !slices.Contains(allEvents, eventType) {
+			return nil, fmt.Errorf("unknown event type %q", name)
+		}
+		filter.types[eventType] = true
+	}
+	if len(filter.types) == 0 {
+		for _, t := range streamedEvents {
+			filter.types[t] = true
+		}
+	}
+	for _, car := range queryList(query, "car") {
+		carID, err := strconv.ParseUint(car, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid car %q", car)
+		}
+		filter.cars[uint8(carID)] = true
+	}
+	return filter, nil
+}
+
+func queryList(query url.Values, key string) []string {
+	var items []string
+	for _, value := range query[key] {
+		items = append(items, splitList(value)...)
+	}
+	return items
+}
+
+// match reports whether e is selected. With a car filter, only events
+// involving one of the cars are selected, including car contacts where it is
+// the other car.
+func (f *eventFilter) match(e Event) bool {
+	if len(f.servers) > 0 && !f.servers[e.Server] {
+		return false
+	}
+	if !f.types[e.Type] {
+		return false
+	}
+	if len(f.cars) == 0 {
+		return true
+	}
+
+	switch data := e.Data.(type) {
+	case *ConnectionEvent:
+		return f.cars[data.CarID]
+	case *CarInfoEvent:
+		return f.cars[data.CarID]
+	case *LapEvent:
+		return f.cars[data.CarID]
+	case *CollisionEvent:
+		return f.cars[data.CarID] || (data.Other != nil && f.cars[data.Other.CarID])
+	case *ChatEvent:
+		return f.cars[data.CarID]
+	}
+	return false
+}
+
+// EventsHandler serves /events: decoded protocol events as JSON, over a
+// WebSocket when the client asks for an upgrade and as Server-Sent Events
+// otherwise. Streams end when ctx is cancelled, so that they do not hold up
+// the server's shutdown.
+func EventsHandler(ctx context.Context, bus *EventBus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseEventFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if websocket.IsWebSocketUpgrade(r) {
+			streamWebSocket(ctx, w, r, bus, filter)
+		} else {
+			streamSSE(ctx, w, r, bus, filter)
+		}
+	}
+}
+
+func streamSSE(ctx context.Context, w http.ResponseWriter, r *http.Request, bus *EventBus, filter *eventFilter) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		slog.Warn("event stream unavailable", "remote", r.RemoteAddr, "err", err)
+		return
+	}
+
+	sub := bus.Subscribe("sse", streamQueueSize, filter.match)
+	defer sub.Close()
+
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			_, err = fmt.Fprint(w, ": keepalive\n\n")
+		case e := <-sub.Events():
+			var data []byte
+			if data, err = json.Marshal(e); err != nil {
+				slog.Error("event encoding failed", "type", string(e.Type), "err", err)
+				continue
+			}
+			rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func streamWebSocket(ctx context.Context, w http.ResponseWriter, r *http.Request, bus *EventBus, filter *eventFilter) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied
+		return
+	}
+	defer conn.Close()
+
+	// Messages from the client are not used, but reading handles pings and
+	// notices when the client goes away.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	sub := bus.Subscribe("websocket", streamQueueSize, filter.match)
+	defer sub.Close()
+
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down"),
+				time.Now().Add(streamWriteTimeout))
+			return
+		case <-closed:
+			return
+		case <-keepalive.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout))
+		case e := <-sub.Events():
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			err = conn.WriteJSON(e)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
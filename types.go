@@ -1,12 +1,112 @@
 package main
 
+import (
+	"math"
+	"time"
+
+	"acserver-exporter/acsp"
+)
+
 type CarInfo struct {
-	CarID       uint8
-	IsConnected bool
-	CarModel    string
-	CarSkin     string
+	CarID       uint8  `json:"car_id"`
+	IsConnected bool   `json:"connected"`
+	CarModel    string `json:"car_model"`
+	CarSkin     string `json:"car_skin"`
+	DriverName  string `json:"driver"`
+	DriverGUID  string `json:"guid"`
+}
+
+// CarTelemetry is the latest realtime position report for a car.
+type CarTelemetry struct {
+	Position            acsp.Vec3
+	Velocity            acsp.Vec3
+	Gear                uint8
+	EngineRPM           uint16
+	NormalizedSplinePos float32
+	UpdatedAt           time.Time
+}
+
+// SpeedKMH returns the magnitude of the velocity vector in km/h.
+func (t *CarTelemetry) SpeedKMH() float64 {
+	v := t.Velocity
+	return math.Sqrt(float64(v.X*v.X+v.Y*v.Y+v.Z*v.Z)) * 3.6
+}
+
+// DisplayGear returns the gear as shown in the car: -1 for reverse, 0 for
+// neutral, 1 and up for forward gears. The protocol reports reverse as 0.
+func (t *CarTelemetry) DisplayGear() int {
+	return int(t.Gear) - 1
+}
+
+// Standing is a row of the live leaderboard sent with every completed lap.
+type Standing struct {
+	Position  int
+	CarID     uint8
+	BestLap   uint32 // ms
+	Laps      uint16
+	Completed bool // finished the session
+}
+
+// HasBestLap reports whether the car has set a valid lap.
+func (s *Standing) HasBestLap() bool {
+	return s.BestLap > 0 && s.BestLap < noLapTime
+}
+
+// DriverKey identifies a driver's laps in a given car on a given track.
+// Drivers without a GUID are told apart by name.
+type DriverKey struct {
+	GUID     string
+	Name     string // only without a GUID
+	CarModel string
+	Track    string
+}
+
+// DriverLapStats accumulates the laps of a driver. It is guarded by the
+// monitor's metricsLock.
+type DriverLapStats struct {
 	DriverName  string
-	DriverGUID  string
+	LapTimes    *histogram // seconds
+	BestLap     uint32     // ms, fastest lap without cuts, 0 if none
+	LastLap     uint32     // ms
+	Cuts        uint64
+	InvalidLaps uint64 // laps with at least one cut
+}
+
+// DriverContacts counts the car-to-car contacts of a driver. It is guarded by
+// the monitor's metricsLock.
+type DriverContacts struct {
+	GUID       string // empty for drivers without one
+	DriverName string
+	Caused     uint64 // reported by the driver's car
+	Received   uint64 // reported by the other car
+}
+
+// SessionState is the session as last reported over UDP.
+type SessionState struct {
+	ServerName string  `json:"server_name"`
+	Track      string  `json:"track"`
+	Type       string  `json:"type"`
+	GripLevel  float32 `json:"grip_level"`
+}
+
+// LapRecord is a completed lap kept in a monitor's lap history.
+type LapRecord struct {
+	Time time.Time `json:"time"`
+	LapEvent
+}
+
+// InfoSnapshot is the result of a successful /INFO poll. Snapshots are
+// replaced, never modified, so readers may keep one after releasing the lock.
+type InfoSnapshot struct {
+	Info      *ServerInfo
+	FetchedAt time.Time
+}
+
+// Liveness is what a monitor last heard from its server.
+type Liveness struct {
+	Info       *InfoSnapshot // latest successful /INFO poll, possibly stale
+	InfoUp     bool          // whether the last /INFO poll succeeded
+	LastPacket time.Time     // last UDP packet, zero if none yet
 }
 
 type ServerInfo struct {
@@ -26,23 +126,3 @@ type ServerInfo struct {
 	TimeOfDay    int      `json:"timeofday"`
 	PoweredBy    string   `json:"poweredBy"`
 }
-
-// ACSP Protocol constants
-const (
-	ACSP_ERROR                = 0
-	ACSP_CHAT                 = 1
-	ACSP_CLIENT_LOADED        = 2
-	ACSP_NEW_SESSION          = 3
-	ACSP_NEW_CONNECTION       = 4
-	ACSP_CONNECTION_CLOSED    = 5
-	ACSP_CAR_UPDATE           = 6
-	ACSP_CAR_INFO             = 7
-	ACSP_END_SESSION          = 8
-	ACSP_LAP_COMPLETED        = 9
-	ACSP_VERSION              = 10
-	ACSP_SESSION_INFO         = 11
-	ACSP_CLIENT_EVENT         = 12
-	ACSP_REALTIMEPOS_INTERVAL = 3
-	ACSP_GET_CAR_INFO         = 4
-	ACSP_GET_SESSION_INFO     = 7
-)